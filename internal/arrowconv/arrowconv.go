@@ -6,6 +6,10 @@ import (
 	"github.com/apache/arrow/go/v7/arrow"
 	"github.com/apache/arrow/go/v7/arrow/array"
 	"github.com/apache/arrow/go/v7/arrow/memory"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
 )
 
 type ArrowConverter struct {
@@ -17,89 +21,129 @@ func NewArrowConverter(pool *memory.GoAllocator) *ArrowConverter {
 }
 
 func (c *ArrowConverter) getSchema(data map[string]interface{}) (*arrow.Schema, error) {
-	fields := make([]arrow.Field, 0)
-	for k, v := range data {
-		switch v.(type){
-		case bool:
-			field := arrow.Field{
-				Name: k,
-				Type: &arrow.BooleanType{},
-				Nullable: false,
-			}
-			fields = append(fields, field)
-		case []byte:
-			field := arrow.Field{
-				Name: k,
-				Type: &arrow.BinaryType{},
-				Nullable: false,
-			}
-			fields = append(fields, field)
-		case float32:
-			field := arrow.Field{
-				Name: k,
-				Type: &arrow.Float32Type{},
-				Nullable: false,
-			}
-			fields = append(fields, field)
-		case float64:
-			field := arrow.Field{
-				Name: k,
-				Type: &arrow.Float64Type{},
-				Nullable: false,
-			}
-			fields = append(fields, field)
-		case int32:
-			field := arrow.Field{
-				Name: k,
-				Type: &arrow.Int32Type{},
-				Nullable: false,
-			}
-			fields = append(fields, field)
-		case int64:
-			field := arrow.Field{
-				Name: k,
-				Type: &arrow.Int64Type{},
-				Nullable: false,
-			}
-			fields = append(fields, field)
-		case string:
-			field := arrow.Field{
-				Name: k,
-				Type: &arrow.StringType{},
-				Nullable: false,
-			}
-			fields = append(fields, field)
-		default:
-			return nil, errors.New("no valid conversion for type")
-		}
+	fields, err := c.getFields(data)
+	if err != nil {
+		return nil, err
 	}
 	meta := arrow.NewMetadata([]string{}, []string{})
 	return arrow.NewSchema(fields, &meta), nil
 }
 
+// getFields returns one field per key of the map. Keys are sorted so the same
+// record shape always produces the same schema, at the top level and in
+// nested records alike.
+func (c *ArrowConverter) getFields(data map[string]interface{}) ([]arrow.Field, error) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]arrow.Field, 0, len(keys))
+	for _, k := range keys {
+		field, err := c.getField(k, data[k])
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func (c *ArrowConverter) getField(name string, val interface{}) (arrow.Field, error) {
+	// a nil value can only come from a union with null, and carries no type.
+	if val == nil {
+		return NewUnionField(name, []UnionBranch{{Name: avroNull}})
+	}
+	// goavro wraps the value of a union in a single entry map keyed by the
+	// branch name.
+	if branch, inner, ok := unwrapPrimitiveUnion(val); ok {
+		dtype, err := c.getType(inner)
+		if err != nil {
+			return arrow.Field{}, err
+		}
+		return NewUnionField(name, []UnionBranch{{Name: avroNull}, {Name: branch, Type: dtype}})
+	}
+	dtype, err := c.getType(val)
+	if err != nil {
+		return arrow.Field{}, err
+	}
+	field := arrow.Field{
+		Name: name,
+		Type: dtype,
+		Nullable: false,
+	}
+	return field, nil
+}
+
+func (c *ArrowConverter) getType(val interface{}) (arrow.DataType, error) {
+	switch v := val.(type) {
+	case bool:
+		return &arrow.BooleanType{}, nil
+	case []byte:
+		return &arrow.BinaryType{}, nil
+	case float32:
+		return &arrow.Float32Type{}, nil
+	case float64:
+		return &arrow.Float64Type{}, nil
+	case int32:
+		return &arrow.Int32Type{}, nil
+	case int64:
+		return &arrow.Int64Type{}, nil
+	case string:
+		return &arrow.StringType{}, nil
+	case map[string]interface{}:
+		// nested avro records are decoded as maps and become struct columns.
+		fields, err := c.getFields(v)
+		if err != nil {
+			return nil, err
+		}
+		return arrow.StructOf(fields...), nil
+	case []interface{}:
+		// avro arrays become list columns typed by their first element. an
+		// empty array gives no hint so it is typed as a list of nulls.
+		if len(v) == 0 {
+			return arrow.ListOf(arrow.Null), nil
+		}
+		elem, err := c.getField("item", v[0])
+		if err != nil {
+			return nil, err
+		}
+		elem.Nullable = true
+		return arrow.ListOfField(elem), nil
+	default:
+		return nil, errors.New("no valid conversion for type")
+	}
+}
+
+// RowError reports which row and top level field a conversion failed on.
+type RowError struct {
+	Row int
+	Field string
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d, field %s: %s", e.Row, e.Field, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
 func (c *ArrowConverter) getRecord(data map[string]interface{}, builder *array.RecordBuilder) (array.Record, error) {
+	return c.getRecords([]map[string]interface{}{data}, builder)
+}
+
+func (c *ArrowConverter) getRecords(rows []map[string]interface{}, builder *array.RecordBuilder) (array.Record, error) {
 	defer builder.Release()
 	schema := builder.Schema()
-	for i, field := range schema.Fields() {
-		val := data[field.Name]
-		switch field.Type.ID() {
-		case arrow.BOOL:
-			builder.Field(i).(*array.BooleanBuilder).Append(val.(bool))
-		case arrow.BINARY:
-			builder.Field(i).(*array.BinaryBuilder).Append(val.([]byte))
-		case arrow.FLOAT32:
-			builder.Field(i).(*array.Float32Builder).Append(val.(float32))
-		case arrow.FLOAT64:
-			builder.Field(i).(*array.Float64Builder).Append(val.(float64))
-		case arrow.INT32:
-			builder.Field(i).(*array.Int32Builder).Append(val.(int32))
-		case arrow.INT64:
-			builder.Field(i).(*array.Int64Builder).Append(val.(int64))
-		case arrow.STRING:
-			builder.Field(i).(*array.StringBuilder).Append(val.(string))
-		default:
-			fmt.Println("got a type we can't handle")
-			return nil, errors.New("got a type we can't handle")
+	for row, data := range rows {
+		for i, field := range schema.Fields() {
+			err := c.appendValue(builder.Field(i), field, data[field.Name])
+			if err != nil {
+				return nil, &RowError{Row: row, Field: field.Name, Err: err}
+			}
 		}
 	}
 	record := builder.NewRecord()
@@ -107,6 +151,169 @@ func (c *ArrowConverter) getRecord(data map[string]interface{}, builder *array.R
 	return record, nil
 }
 
+func (c *ArrowConverter) appendValue(builder array.Builder, field arrow.Field, val interface{}) error {
+	if branches, ok := unionBranches(field); ok {
+		return c.appendUnion(builder, field, branches, val)
+	}
+	if val == nil {
+		if !field.Nullable {
+			return fmt.Errorf("field %s is not nullable", field.Name)
+		}
+		builder.AppendNull()
+		return nil
+	}
+	dtype := field.Type
+	switch dtype.ID() {
+	case arrow.BOOL:
+		b, ok := val.(bool)
+		if !ok {
+			return conversionError(val, dtype)
+		}
+		builder.(*array.BooleanBuilder).Append(b)
+	case arrow.BINARY:
+		b, ok := val.([]byte)
+		if !ok {
+			return conversionError(val, dtype)
+		}
+		builder.(*array.BinaryBuilder).Append(b)
+	case arrow.FLOAT32:
+		f, ok := val.(float32)
+		if !ok {
+			return conversionError(val, dtype)
+		}
+		builder.(*array.Float32Builder).Append(f)
+	case arrow.FLOAT64:
+		f, ok := val.(float64)
+		if !ok {
+			return conversionError(val, dtype)
+		}
+		builder.(*array.Float64Builder).Append(f)
+	case arrow.INT32:
+		n, ok := val.(int32)
+		if !ok {
+			return conversionError(val, dtype)
+		}
+		builder.(*array.Int32Builder).Append(n)
+	case arrow.INT64:
+		n, ok := val.(int64)
+		if !ok {
+			return conversionError(val, dtype)
+		}
+		builder.(*array.Int64Builder).Append(n)
+	case arrow.STRING:
+		str, ok := val.(string)
+		if !ok {
+			return conversionError(val, dtype)
+		}
+		err := validateEnum(field, str)
+		if err != nil {
+			return err
+		}
+		builder.(*array.StringBuilder).Append(str)
+	case arrow.FIXED_SIZE_BINARY:
+		b, ok := val.([]byte)
+		width := dtype.(*arrow.FixedSizeBinaryType).ByteWidth
+		if !ok || len(b) != width {
+			return fmt.Errorf("could not convert %T to fixed of size %d", val, width)
+		}
+		builder.(*array.FixedSizeBinaryBuilder).Append(b)
+	case arrow.DATE32:
+		t, ok := val.(time.Time)
+		if !ok {
+			return fmt.Errorf("could not convert %T to a date", val)
+		}
+		builder.(*array.Date32Builder).Append(date32FromTime(t))
+	case arrow.TIME32:
+		d, ok := val.(time.Duration)
+		if !ok {
+			return fmt.Errorf("could not convert %T to a time of day", val)
+		}
+		unit := dtype.(*arrow.Time32Type).Unit
+		builder.(*array.Time32Builder).Append(arrow.Time32(d / unit.Multiplier()))
+	case arrow.TIME64:
+		d, ok := val.(time.Duration)
+		if !ok {
+			return fmt.Errorf("could not convert %T to a time of day", val)
+		}
+		unit := dtype.(*arrow.Time64Type).Unit
+		builder.(*array.Time64Builder).Append(arrow.Time64(d / unit.Multiplier()))
+	case arrow.TIMESTAMP:
+		t, ok := val.(time.Time)
+		if !ok {
+			return fmt.Errorf("could not convert %T to a timestamp", val)
+		}
+		unit := dtype.(*arrow.TimestampType).Unit
+		builder.(*array.TimestampBuilder).Append(timestampFromTime(t, unit))
+	case arrow.DECIMAL:
+		r, ok := val.(*big.Rat)
+		if !ok {
+			return fmt.Errorf("could not convert %T to a decimal", val)
+		}
+		n, err := decimalFromRat(r, dtype.(*arrow.Decimal128Type))
+		if err != nil {
+			return err
+		}
+		builder.(*array.Decimal128Builder).Append(n)
+	case arrow.STRUCT:
+		nested, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("could not convert %T to a nested record", val)
+		}
+		sb := builder.(*array.StructBuilder)
+		sb.Append(true)
+		for i, child := range dtype.(*arrow.StructType).Fields() {
+			err := c.appendValue(sb.FieldBuilder(i), child, nested[child.Name])
+			if err != nil {
+				return err
+			}
+		}
+	case arrow.LIST:
+		items, ok := val.([]interface{})
+		if !ok {
+			return fmt.Errorf("could not convert %T to an array", val)
+		}
+		lb := builder.(*array.ListBuilder)
+		lb.Append(true)
+		elem := dtype.(*arrow.ListType).ElemField()
+		for _, item := range items {
+			err := c.appendValue(lb.ValueBuilder(), elem, item)
+			if err != nil {
+				return err
+			}
+		}
+	case arrow.MAP:
+		entries, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("could not convert %T to a map", val)
+		}
+		mt := dtype.(*arrow.MapType)
+		if mt.KeyType().ID() != arrow.STRING {
+			return errors.New("avro map keys must be strings")
+		}
+		mb := builder.(*array.MapBuilder)
+		mb.Append(true)
+		keys := make([]string, 0, len(entries))
+		for k := range entries {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			mb.KeyBuilder().(*array.StringBuilder).Append(k)
+			err := c.appendValue(mb.ItemBuilder(), mapItemField(field, mt), entries[k])
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("got a type we can't handle")
+	}
+	return nil
+}
+
+func conversionError(val interface{}, dtype arrow.DataType) error {
+	return fmt.Errorf("could not convert %T to %s", val, dtype)
+}
+
 func (c *ArrowConverter) MapToArrow(data map[string]interface{}) (array.Record, error) {
 	schema, err := c.getSchema(data)
 	if err != nil {
@@ -118,58 +325,259 @@ func (c *ArrowConverter) MapToArrow(data map[string]interface{}) (array.Record,
 	return c.getRecord(data, builder)
 }
 
+// MapToArrowWithSchema converts the map using the given schema rather than one
+// inferred from the values. Avro records and maps both decode to
+// map[string]interface{}, so a schema is needed to produce map columns.
+func (c *ArrowConverter) MapToArrowWithSchema(data map[string]interface{}, schema *arrow.Schema) (array.Record, error) {
+	builder := array.NewRecordBuilder(c.pool, schema)
+	builder.Retain()
+
+	return c.getRecord(data, builder)
+}
+
+// MapsToArrow converts every row into a single record, inferring the schema
+// from the first row.
+func (c *ArrowConverter) MapsToArrow(data []map[string]interface{}) (array.Record, error) {
+	if len(data) == 0 {
+		return nil, errors.New("no rows to infer a schema from")
+	}
+	schema, err := c.getSchema(data[0])
+	if err != nil {
+		return nil, err
+	}
+	return c.MapsToArrowWithSchema(data, schema)
+}
+
+// MapsToArrowWithSchema converts every row into a single record using the
+// given schema. A row that can't be converted fails the whole record with a
+// *RowError naming the row and field.
+func (c *ArrowConverter) MapsToArrowWithSchema(data []map[string]interface{}, schema *arrow.Schema) (array.Record, error) {
+	builder := array.NewRecordBuilder(c.pool, schema)
+	builder.Retain()
+
+	return c.getRecords(data, builder)
+}
+
+// ArrowToMaps reads every row of the record, in order. Like ArrowToMap it
+// releases the record.
+func (c *ArrowConverter) ArrowToMaps(record array.Record) ([]map[string]interface{}, error) {
+	defer record.Release()
+	s := record.Schema()
+	rows := make([]map[string]interface{}, 0, record.NumRows())
+	for row := 0; row < int(record.NumRows()); row++ {
+		result := make(map[string]interface{})
+		for i, column := range record.Columns() {
+			field := s.Field(i)
+			val, err := c.readValue(column, field, row)
+			if err != nil {
+				return rows, &RowError{Row: row, Field: field.Name, Err: err}
+			}
+			result[field.Name] = val
+		}
+		rows = append(rows, result)
+	}
+	return rows, nil
+}
+
 func (c *ArrowConverter) ArrowToMap(record array.Record) (map[string]interface{}, error) {
 	defer record.Release()
 	result := make(map[string]interface{})
 	s := record.Schema()
 	for i, column := range record.Columns() {
 		field := s.Field(i)
-		switch field.Type.ID() {
-		case arrow.BOOL:
-			col, ok := column.(*array.Boolean)
-			if !ok {
-				return result, errors.New("could not convert column to *array.Boolean")
-			}
-			result[field.Name] = col.Value(0)
-		case arrow.BINARY:
-			col, ok := column.(*array.Binary)
-			if !ok {
-				return result, errors.New("could not convert column to *array.Binary")
-			}
-			result[field.Name] = col.Value(0)
-		case arrow.FLOAT32:
-			col, ok := column.(*array.Float32)
-			if !ok {
-				return result, errors.New("could not convert column to *array.Float32")
-			}
-			result[field.Name] = col.Value(0)
-		case arrow.FLOAT64:
-			col, ok := column.(*array.Float64)
-			if !ok {
-				return result, errors.New("could not convert column to *array.Float64")
-			}
-			result[field.Name] = col.Value(0)
-		case arrow.INT32:
-			col, ok := column.(*array.Int32)
-			if !ok {
-				return result, errors.New("could not convert column to *array.Int32")
+		val, err := c.readValue(column, field, 0)
+		if err != nil {
+			return result, err
+		}
+		result[field.Name] = val
+	}
+	return result, nil
+}
+
+func (c *ArrowConverter) readValue(column array.Interface, field arrow.Field, row int) (interface{}, error) {
+	if column.IsNull(row) {
+		return nil, nil
+	}
+	if branches, ok := unionBranches(field); ok {
+		return c.readUnion(column, field, branches, row)
+	}
+	dtype := field.Type
+	switch dtype.ID() {
+	case arrow.NULL:
+		return nil, nil
+	case arrow.BOOL:
+		col, ok := column.(*array.Boolean)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Boolean")
+		}
+		return col.Value(row), nil
+	case arrow.BINARY:
+		col, ok := column.(*array.Binary)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Binary")
+		}
+		return col.Value(row), nil
+	case arrow.FLOAT32:
+		col, ok := column.(*array.Float32)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Float32")
+		}
+		return col.Value(row), nil
+	case arrow.FLOAT64:
+		col, ok := column.(*array.Float64)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Float64")
+		}
+		return col.Value(row), nil
+	case arrow.INT32:
+		col, ok := column.(*array.Int32)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Int32")
+		}
+		return col.Value(row), nil
+	case arrow.INT64:
+		col, ok := column.(*array.Int64)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Int64")
+		}
+		return col.Value(row), nil
+	case arrow.STRING:
+		col, ok := column.(*array.String)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.String")
+		}
+		str := col.Value(row)
+		err := validateEnum(field, str)
+		if err != nil {
+			return nil, err
+		}
+		return str, nil
+	case arrow.FIXED_SIZE_BINARY:
+		col, ok := column.(*array.FixedSizeBinary)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.FixedSizeBinary")
+		}
+		return col.Value(row), nil
+	case arrow.DATE32:
+		col, ok := column.(*array.Date32)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Date32")
+		}
+		return timeFromDate32(col.Value(row)), nil
+	case arrow.TIME32:
+		col, ok := column.(*array.Time32)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Time32")
+		}
+		unit := dtype.(*arrow.Time32Type).Unit
+		return time.Duration(col.Value(row)) * unit.Multiplier(), nil
+	case arrow.TIME64:
+		col, ok := column.(*array.Time64)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Time64")
+		}
+		unit := dtype.(*arrow.Time64Type).Unit
+		return time.Duration(col.Value(row)) * unit.Multiplier(), nil
+	case arrow.TIMESTAMP:
+		col, ok := column.(*array.Timestamp)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Timestamp")
+		}
+		unit := dtype.(*arrow.TimestampType).Unit
+		return timeFromTimestamp(col.Value(row), unit), nil
+	case arrow.DECIMAL:
+		col, ok := column.(*array.Decimal128)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Decimal128")
+		}
+		return ratFromDecimal(col.Value(row), dtype.(*arrow.Decimal128Type)), nil
+	case arrow.STRUCT:
+		col, ok := column.(*array.Struct)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Struct")
+		}
+		nested := make(map[string]interface{})
+		for i, child := range dtype.(*arrow.StructType).Fields() {
+			val, err := c.readValue(col.Field(i), child, row)
+			if err != nil {
+				return nil, err
 			}
-			result[field.Name] = col.Value(0)
-		case arrow.INT64:
-			col, ok := column.(*array.Int64)
-			if !ok {
-				return result, errors.New("could not convert column to *array.Int64")
+			nested[child.Name] = val
+		}
+		return nested, nil
+	case arrow.LIST:
+		col, ok := column.(*array.List)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.List")
+		}
+		elem := dtype.(*arrow.ListType).ElemField()
+		start, end := listBounds(col, row)
+		items := make([]interface{}, 0, end-start)
+		for i := start; i < end; i++ {
+			val, err := c.readValue(col.ListValues(), elem, i)
+			if err != nil {
+				return nil, err
 			}
-			result[field.Name] = col.Value(0)
-		case arrow.STRING:
-			col, ok := column.(*array.String)
-			if !ok {
-				return result, errors.New("could not convert column to *array.String")
+			items = append(items, val)
+		}
+		return items, nil
+	case arrow.MAP:
+		col, ok := column.(*array.Map)
+		if !ok {
+			return nil, errors.New("could not convert column to *array.Map")
+		}
+		mt := dtype.(*arrow.MapType)
+		keys, ok := col.Keys().(*array.String)
+		if !ok {
+			return nil, errors.New("avro map keys must be strings")
+		}
+		start, end := listBounds(col.List, row)
+		entries := make(map[string]interface{}, end-start)
+		for i := start; i < end; i++ {
+			val, err := c.readValue(col.Items(), mapItemField(field, mt), i)
+			if err != nil {
+				return nil, err
 			}
-			result[field.Name] = col.Value(0)
-		default:
-			return nil, errors.New("no conversion from arrow type to avro")
+			entries[keys.Value(i)] = val
 		}
+		return entries, nil
+	default:
+		return nil, errors.New("no conversion from arrow type to avro")
 	}
-	return result, nil
-}
\ No newline at end of file
+}
+
+// listBounds returns the range of child values that make up the given row.
+func listBounds(col *array.List, row int) (int, int) {
+	offsets := col.Offsets()
+	i := row + col.Data().Offset()
+	return int(offsets[i]), int(offsets[i+1])
+}
+
+// Arrow's map type keeps no metadata on its item field, so the metadata of
+// avro map values, such as their union branches or enum symbols, is kept on
+// the map field with mapValueMetadataPrefix in front of each key.
+const mapValueMetadataPrefix = "avro.map.value."
+
+func mapValueMetadata(item arrow.Metadata) arrow.Metadata {
+	keys := make([]string, 0, item.Len())
+	for _, key := range item.Keys() {
+		keys = append(keys, mapValueMetadataPrefix+key)
+	}
+	return arrow.NewMetadata(keys, item.Values())
+}
+
+// mapItemField returns the item field of a map field along with the metadata
+// of its values.
+func mapItemField(field arrow.Field, mt *arrow.MapType) arrow.Field {
+	item := mt.ItemField()
+	keys := make([]string, 0, field.Metadata.Len())
+	values := make([]string, 0, field.Metadata.Len())
+	for i, key := range field.Metadata.Keys() {
+		if strings.HasPrefix(key, mapValueMetadataPrefix) {
+			keys = append(keys, strings.TrimPrefix(key, mapValueMetadataPrefix))
+			values = append(values, field.Metadata.Values()[i])
+		}
+	}
+	item.Metadata = arrow.NewMetadata(keys, values)
+	return item
+}
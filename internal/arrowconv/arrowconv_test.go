@@ -249,3 +249,252 @@ func TestArrowConverter_ArrowToMap(t *testing.T) {
 	assert.Nil(t, err, "there should be no error converting from arrow record to map")
 	validateMap(t, result)
 }
+
+func getNestedTestMap() map[string]interface{} {
+	geo := make(map[string]interface{})
+	geo["lat"] = float64(40.7)
+	geo["lon"] = float64(-74.0)
+
+	address := make(map[string]interface{})
+	address["zip"] = "10001"
+	address["geo"] = geo
+
+	data := make(map[string]interface{})
+	data["app_id"] = "1000"
+	data["credit_score"] = int32(800)
+	data["address"] = address
+	return data
+}
+
+func TestArrowConverter_getSchema_nested(t *testing.T) {
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	schema, err := conv.getSchema(getNestedTestMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// verify
+	address, exists := schema.FieldsByName("address")
+	assert.True(t, exists, "address should exist in the schema")
+	assert.Equal(t, arrow.STRUCT, address[0].Type.ID(), "the type of address must be a struct")
+	addressType := address[0].Type.(*arrow.StructType)
+	assert.Equal(t, []string{"geo", "zip"}, []string{addressType.Field(0).Name, addressType.Field(1).Name},
+		"nested fields should be sorted by name")
+
+	geo, exists := addressType.FieldByName("geo")
+	assert.True(t, exists, "geo should exist in the address struct")
+	assert.Equal(t, arrow.STRUCT, geo.Type.ID(), "the type of geo must be a struct")
+	lat, exists := geo.Type.(*arrow.StructType).FieldByName("lat")
+	assert.True(t, exists, "lat should exist in the geo struct")
+	assert.Equal(t, arrow.FLOAT64, lat.Type.ID(), "the type of lat must be a float64")
+}
+
+func TestArrowConverter_MapToArrow_ArrowToMap_nested(t *testing.T) {
+	data := getNestedTestMap()
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	record, err := conv.MapToArrow(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run the test.
+	result, err := conv.ArrowToMap(record)
+	assert.Nil(t, err, "there should be no error converting nested records from arrow to map")
+	assert.Equal(t, data, result, "nested records should round trip through arrow")
+}
+
+func TestArrowConverter_MapToArrow_nested_err(t *testing.T) {
+	type FakeStruct struct{}
+	data := getNestedTestMap()
+	data["address"].(map[string]interface{})["geo"].(map[string]interface{})["lat"] = FakeStruct{}
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	result, err := conv.MapToArrow(data)
+	if err == nil {
+		defer result.Release()
+	}
+	assert.NotNil(t, err, "there should be an error converting a nested type that has no valid conversion")
+}
+
+func TestArrowConverter_getSchema_list(t *testing.T) {
+	data := make(map[string]interface{})
+	data["txn_amounts"] = []interface{}{float64(10.5), float64(20.0)}
+	data["empty"] = []interface{}{}
+
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	schema, err := conv.getSchema(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// verify
+	amounts, exists := schema.FieldsByName("txn_amounts")
+	assert.True(t, exists, "txn_amounts should exist in the schema")
+	assert.Equal(t, arrow.LIST, amounts[0].Type.ID(), "the type of txn_amounts must be a list")
+	assert.Equal(t, arrow.FLOAT64, amounts[0].Type.(*arrow.ListType).Elem().ID(),
+		"the element type of txn_amounts must be a float64")
+	empty, exists := schema.FieldsByName("empty")
+	assert.True(t, exists, "empty should exist in the schema")
+	assert.Equal(t, arrow.NULL, empty[0].Type.(*arrow.ListType).Elem().ID(),
+		"the element type of an empty list must be null")
+}
+
+func TestArrowConverter_MapToArrow_ArrowToMap_list(t *testing.T) {
+	txn := make(map[string]interface{})
+	txn["amount"] = float64(10.5)
+	txn["merchant"] = "acme"
+
+	data := make(map[string]interface{})
+	data["txn_amounts"] = []interface{}{float64(10.5), float64(20.0), float64(7.25)}
+	data["txns"] = []interface{}{txn}
+	data["windows"] = []interface{}{[]interface{}{int32(1), int32(2)}, []interface{}{int32(3)}}
+	data["empty"] = []interface{}{}
+
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	record, err := conv.MapToArrow(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run the test.
+	result, err := conv.ArrowToMap(record)
+	assert.Nil(t, err, "there should be no error converting lists from arrow to map")
+	assert.Equal(t, data, result, "lists should round trip through arrow")
+}
+
+func TestArrowConverter_MapToArrowWithSchema_map(t *testing.T) {
+	data := make(map[string]interface{})
+	data["app_id"] = "1000"
+	data["balances"] = map[string]interface{}{"checking": float64(100.0), "savings": float64(2500.0)}
+	data["history"] = map[string]interface{}{"checking": []interface{}{float64(1.0), float64(2.0)}}
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "app_id", Type: &arrow.StringType{}},
+		{Name: "balances", Type: arrow.MapOf(&arrow.StringType{}, &arrow.Float64Type{})},
+		{Name: "history", Type: arrow.MapOf(&arrow.StringType{}, arrow.ListOf(&arrow.Float64Type{}))},
+	}, nil)
+
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	record, err := conv.MapToArrowWithSchema(data, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, arrow.MAP, record.Column(1).DataType().ID(), "balances should be a map column")
+
+	// run the test.
+	result, err := conv.ArrowToMap(record)
+	assert.Nil(t, err, "there should be no error converting maps from arrow to map")
+	assert.Equal(t, data, result, "maps should round trip through arrow")
+}
+
+func TestArrowConverter_MapToArrowWithSchema_map_err(t *testing.T) {
+	data := make(map[string]interface{})
+	data["balances"] = []interface{}{float64(100.0)}
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "balances", Type: arrow.MapOf(&arrow.StringType{}, &arrow.Float64Type{})},
+	}, nil)
+
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	result, err := conv.MapToArrowWithSchema(data, schema)
+	if err == nil {
+		defer result.Release()
+	}
+	assert.NotNil(t, err, "there should be an error converting an array to a map column")
+}
+
+func getMultiRowTestSchema() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "app_id", Type: &arrow.StringType{}},
+		{Name: "credit_score", Type: &arrow.Int32Type{}, Nullable: true},
+		{Name: "balances", Type: arrow.MapOf(&arrow.StringType{}, &arrow.Float64Type{})},
+		{Name: "txn_amounts", Type: arrow.ListOf(&arrow.Float64Type{})},
+	}, nil)
+}
+
+func getMultiRowTestMaps() []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"app_id": "1000",
+			"credit_score": int32(800),
+			"balances": map[string]interface{}{"checking": float64(100.0)},
+			"txn_amounts": []interface{}{float64(10.5), float64(20.0)},
+		},
+		{
+			"app_id": "1001",
+			"credit_score": nil,
+			"balances": map[string]interface{}{},
+			"txn_amounts": []interface{}{},
+		},
+		{
+			"app_id": "1002",
+			"credit_score": int32(650),
+			"balances": map[string]interface{}{"checking": float64(5.0), "savings": float64(7.5)},
+			"txn_amounts": []interface{}{float64(1.0)},
+		},
+	}
+}
+
+func TestArrowConverter_MapsToArrowWithSchema_ArrowToMaps(t *testing.T) {
+	data := getMultiRowTestMaps()
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	record, err := conv.MapsToArrowWithSchema(data, getMultiRowTestSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(3), record.NumRows(), "there should be one arrow row per map")
+
+	// run the test.
+	result, err := conv.ArrowToMaps(record)
+	assert.Nil(t, err, "there should be no error converting rows from arrow to maps")
+	assert.Equal(t, data, result, "every row should round trip through arrow in order")
+}
+
+func TestArrowConverter_ArrowToMaps_slice(t *testing.T) {
+	data := getMultiRowTestMaps()
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	record, err := conv.MapsToArrowWithSchema(data, getMultiRowTestSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer record.Release()
+
+	// run the test.
+	result, err := conv.ArrowToMaps(record.NewSlice(1, 3))
+	assert.Nil(t, err, "there should be no error converting a slice of a record")
+	assert.Equal(t, data[1:], result, "a slice should read only its own rows")
+}
+
+func TestArrowConverter_MapsToArrow(t *testing.T) {
+	data := []map[string]interface{}{getTestMap(), getTestMap()}
+	data[1]["colA"] = "2000"
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	record, err := conv.MapsToArrow(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run the test.
+	result, err := conv.ArrowToMaps(record)
+	assert.Nil(t, err, "there should be no error converting rows from arrow to maps")
+	assert.Equal(t, data, result, "rows with an inferred schema should round trip through arrow")
+}
+
+func TestArrowConverter_MapsToArrow_empty(t *testing.T) {
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	_, err := conv.MapsToArrow(nil)
+	assert.NotNil(t, err, "there should be an error inferring a schema without any rows")
+}
+
+func TestArrowConverter_MapsToArrowWithSchema_row_error(t *testing.T) {
+	data := getMultiRowTestMaps()
+	data[2]["txn_amounts"] = []interface{}{"not a number"}
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	result, err := conv.MapsToArrowWithSchema(data, getMultiRowTestSchema())
+	if err == nil {
+		defer result.Release()
+	}
+
+	var rowErr *RowError
+	if assert.ErrorAs(t, err, &rowErr, "the error should identify the row") {
+		assert.Equal(t, 2, rowErr.Row, "the error should name the offending row")
+		assert.Equal(t, "txn_amounts", rowErr.Field, "the error should name the offending field")
+	}
+}
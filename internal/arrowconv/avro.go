@@ -0,0 +1,252 @@
+package arrowconv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"strings"
+)
+
+// SchemaFromAvro builds the arrow schema for an avro record schema. Fields keep
+// the order they are declared in, avro int and long map to int32 and int64 to
+// match what goavro decodes, logical types map to the matching arrow temporal
+// and decimal types, and unions become nullable or struct fields as described
+// in NewUnionField.
+func SchemaFromAvro(avroSchema string) (*arrow.Schema, error) {
+	var schema interface{}
+	err := json.Unmarshal([]byte(avroSchema), &schema)
+	if err != nil {
+		return nil, err
+	}
+	p := &avroParser{
+		named: make(map[string]arrow.DataType),
+		enums: make(map[string][]string),
+		mapValues: make(map[arrow.DataType]arrow.Metadata),
+	}
+	dtype, _, err := p.parse(schema, "")
+	if err != nil {
+		return nil, err
+	}
+	st, ok := dtype.(*arrow.StructType)
+	if !ok {
+		return nil, errors.New("avro schema must be a record")
+	}
+	meta := arrow.NewMetadata([]string{}, []string{})
+	return arrow.NewSchema(st.Fields(), &meta), nil
+}
+
+// avroParser tracks named types so later references to them can be resolved.
+type avroParser struct {
+	named map[string]arrow.DataType
+	// enums holds the symbols of each enum by its full name.
+	enums map[string][]string
+	// mapValues holds the metadata of the values of each map type.
+	mapValues map[arrow.DataType]arrow.Metadata
+}
+
+// parse returns the arrow type for an avro type along with the name goavro
+// uses for it as a union branch.
+func (p *avroParser) parse(schema interface{}, namespace string) (arrow.DataType, string, error) {
+	switch s := schema.(type) {
+	case string:
+		return p.parseName(s, namespace)
+	case []interface{}:
+		field, err := p.parseUnion("", s, namespace)
+		if err != nil {
+			return nil, "", err
+		}
+		return field.Type, "", nil
+	case map[string]interface{}:
+		return p.parseComplex(s, namespace)
+	default:
+		return nil, "", fmt.Errorf("invalid avro schema: %v", schema)
+	}
+}
+
+func (p *avroParser) parseName(name, namespace string) (arrow.DataType, string, error) {
+	switch name {
+	case "null":
+		return arrow.Null, name, nil
+	case "boolean":
+		return &arrow.BooleanType{}, name, nil
+	case "int":
+		return &arrow.Int32Type{}, name, nil
+	case "long":
+		return &arrow.Int64Type{}, name, nil
+	case "float":
+		return &arrow.Float32Type{}, name, nil
+	case "double":
+		return &arrow.Float64Type{}, name, nil
+	case "bytes":
+		return &arrow.BinaryType{}, name, nil
+	case "string":
+		return &arrow.StringType{}, name, nil
+	}
+	fullName := qualify(name, namespace)
+	dtype, ok := p.named[fullName]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown avro type: %s", name)
+	}
+	return dtype, fullName, nil
+}
+
+func (p *avroParser) parseComplex(schema map[string]interface{}, namespace string) (arrow.DataType, string, error) {
+	typeName, ok := schema["type"].(string)
+	if !ok {
+		// the type attribute may itself be a complex schema.
+		return p.parse(schema["type"], namespace)
+	}
+	if logicalType, ok := schema["logicalType"].(string); ok {
+		dtype, branch, ok, err := p.parseLogical(typeName, logicalType, schema, namespace)
+		if ok {
+			return dtype, branch, err
+		}
+	}
+	switch typeName {
+	case "record", "error":
+		fullName, ns, err := p.fullName(schema, namespace)
+		if err != nil {
+			return nil, "", err
+		}
+		rawFields, ok := schema["fields"].([]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("record %s has no fields", fullName)
+		}
+		fields := make([]arrow.Field, 0, len(rawFields))
+		for _, rawField := range rawFields {
+			field, err := p.parseField(rawField, ns)
+			if err != nil {
+				return nil, "", fmt.Errorf("record %s: %w", fullName, err)
+			}
+			fields = append(fields, field)
+		}
+		dtype := arrow.StructOf(fields...)
+		p.named[fullName] = dtype
+		return dtype, fullName, nil
+	case "enum":
+		fullName, _, err := p.fullName(schema, namespace)
+		if err != nil {
+			return nil, "", err
+		}
+		rawSymbols, _ := schema["symbols"].([]interface{})
+		symbols := make([]string, 0, len(rawSymbols))
+		for _, rawSymbol := range rawSymbols {
+			symbol, ok := rawSymbol.(string)
+			if !ok {
+				return nil, "", fmt.Errorf("enum %s has an invalid symbol: %v", fullName, rawSymbol)
+			}
+			symbols = append(symbols, symbol)
+		}
+		if len(symbols) == 0 {
+			return nil, "", fmt.Errorf("enum %s has no symbols", fullName)
+		}
+		dtype := &arrow.StringType{}
+		p.named[fullName] = dtype
+		p.enums[fullName] = symbols
+		return dtype, fullName, nil
+	case "fixed":
+		fullName, _, err := p.fullName(schema, namespace)
+		if err != nil {
+			return nil, "", err
+		}
+		size, ok := schema["size"].(float64)
+		if !ok || size < 1 {
+			return nil, "", fmt.Errorf("fixed %s has an invalid size", fullName)
+		}
+		dtype := &arrow.FixedSizeBinaryType{ByteWidth: int(size)}
+		p.named[fullName] = dtype
+		return dtype, fullName, nil
+	case "array":
+		elem, err := p.parseNamedField("item", schema["items"], namespace)
+		if err != nil {
+			return nil, "", err
+		}
+		elem.Nullable = true
+		return arrow.ListOfField(elem), typeName, nil
+	case "map":
+		item, err := p.parseNamedField("value", schema["values"], namespace)
+		if err != nil {
+			return nil, "", err
+		}
+		dtype := arrow.MapOf(&arrow.StringType{}, item.Type)
+		p.mapValues[dtype] = item.Metadata
+		return dtype, typeName, nil
+	default:
+		return p.parseName(typeName, namespace)
+	}
+}
+
+func (p *avroParser) parseField(rawField interface{}, namespace string) (arrow.Field, error) {
+	field, ok := rawField.(map[string]interface{})
+	if !ok {
+		return arrow.Field{}, fmt.Errorf("invalid field: %v", rawField)
+	}
+	name, ok := field["name"].(string)
+	if !ok {
+		return arrow.Field{}, fmt.Errorf("field has no name: %v", rawField)
+	}
+	return p.parseNamedField(name, field["type"], namespace)
+}
+
+func (p *avroParser) parseNamedField(name string, schema interface{}, namespace string) (arrow.Field, error) {
+	if branches, ok := schema.([]interface{}); ok {
+		return p.parseUnion(name, branches, namespace)
+	}
+	dtype, branch, err := p.parse(schema, namespace)
+	if err != nil {
+		return arrow.Field{}, fmt.Errorf("field %s: %w", name, err)
+	}
+	return arrow.Field{Name: name, Type: dtype, Nullable: false, Metadata: p.metadata(dtype, branch)}, nil
+}
+
+func (p *avroParser) parseUnion(name string, schemas []interface{}, namespace string) (arrow.Field, error) {
+	branches := make([]UnionBranch, 0, len(schemas))
+	for _, schema := range schemas {
+		dtype, branch, err := p.parse(schema, namespace)
+		if err != nil {
+			return arrow.Field{}, fmt.Errorf("field %s: %w", name, err)
+		}
+		if branch == "" {
+			return arrow.Field{}, fmt.Errorf("field %s: unions may not contain unions", name)
+		}
+		branches = append(branches, UnionBranch{Name: branch, Type: dtype, Metadata: p.metadata(dtype, branch)})
+	}
+	return NewUnionField(name, branches)
+}
+
+// metadata returns the field metadata needed to describe the type, the
+// symbols of a named enum or the metadata of a map's values.
+func (p *avroParser) metadata(dtype arrow.DataType, name string) arrow.Metadata {
+	if symbols, ok := p.enums[name]; ok {
+		return enumMetadata(symbols)
+	}
+	if item, ok := p.mapValues[dtype]; ok {
+		return mapValueMetadata(item)
+	}
+	return arrow.Metadata{}
+}
+
+// fullName returns the full name of a named type and the namespace its
+// children inherit.
+func (p *avroParser) fullName(schema map[string]interface{}, namespace string) (string, string, error) {
+	name, ok := schema["name"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("named type has no name: %v", schema)
+	}
+	if ns, ok := schema["namespace"].(string); ok && !strings.Contains(name, ".") {
+		namespace = ns
+	}
+	fullName := qualify(name, namespace)
+	if i := strings.LastIndex(fullName, "."); i >= 0 {
+		return fullName, fullName[:i], nil
+	}
+	return fullName, "", nil
+}
+
+func qualify(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
@@ -0,0 +1,170 @@
+package arrowconv
+
+import (
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const testAvroSchema = `{
+	"type": "record",
+	"name": "ultra_risk_version_6_19",
+	"namespace": "com.example.risk",
+	"fields": [
+		{"name": "app_id", "type": "string"},
+		{"name": "credit_score", "type": "int"},
+		{"name": "bank_balance_30_days", "type": "long"},
+		{"name": "utilization", "type": ["null", "double"]},
+		{"name": "address", "type": {"type": "record", "name": "Address", "fields": [
+			{"name": "zip", "type": "string"},
+			{"name": "verified", "type": "boolean"}
+		]}},
+		{"name": "previous_address", "type": ["null", "Address"]},
+		{"name": "txn_amounts", "type": {"type": "array", "items": "float"}},
+		{"name": "balances", "type": {"type": "map", "values": "double"}},
+		{"name": "reference", "type": ["null", "string", "long"]}
+	]
+}`
+
+func TestSchemaFromAvro(t *testing.T) {
+	schema, err := SchemaFromAvro(testAvroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// verify the field order matches the avro record.
+	names := make([]string, 0)
+	for _, field := range schema.Fields() {
+		names = append(names, field.Name)
+	}
+	assert.Equal(t, []string{"app_id", "credit_score", "bank_balance_30_days", "utilization", "address",
+		"previous_address", "txn_amounts", "balances", "reference"}, names, "fields should be in avro order")
+
+	// verify the types.
+	assert.Equal(t, arrow.INT32, schema.Field(1).Type.ID(), "avro int must be an int32")
+	assert.Equal(t, arrow.INT64, schema.Field(2).Type.ID(), "avro long must be an int64")
+	assert.False(t, schema.Field(2).Nullable, "a field that is not a union must not be nullable")
+	assert.Equal(t, arrow.FLOAT64, schema.Field(3).Type.ID(), "a nullable double must be a float64")
+	assert.True(t, schema.Field(3).Nullable, "a union with null must be nullable")
+	assert.Equal(t, arrow.STRUCT, schema.Field(4).Type.ID(), "a nested record must be a struct")
+	assert.True(t, arrow.TypeEqual(schema.Field(4).Type, schema.Field(5).Type),
+		"a reference to a named type must resolve to the same type")
+	assert.Equal(t, arrow.LIST, schema.Field(6).Type.ID(), "an avro array must be a list")
+	assert.Equal(t, arrow.MAP, schema.Field(7).Type.ID(), "an avro map must be a map")
+	assert.Equal(t, arrow.STRUCT, schema.Field(8).Type.ID(), "a multi-branch union must be a struct")
+}
+
+func TestSchemaFromAvro_round_trip(t *testing.T) {
+	codec, err := goavro.NewCodec(testAvroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := SchemaFromAvro(testAvroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := map[string]interface{}{"zip": "10001", "verified": true}
+	native := map[string]interface{}{
+		"app_id": "1000",
+		"credit_score": 800,
+		"bank_balance_30_days": 50000,
+		"utilization": goavro.Union("double", 0.3),
+		"address": address,
+		"previous_address": goavro.Union("com.example.risk.Address", address),
+		"txn_amounts": []interface{}{float32(1.5), float32(2.5)},
+		"balances": map[string]interface{}{"checking": 100.0},
+		"reference": goavro.Union("long", 42),
+	}
+	binary, err := codec.BinaryFromNative(nil, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datum, _, err := codec.NativeFromBinary(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run the test.
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	record, err := conv.MapToArrowWithSchema(datum.(map[string]interface{}), schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := conv.ArrowToMap(record)
+	assert.Nil(t, err, "there should be no error converting from arrow to map")
+	assert.Equal(t, datum, result, "decoded avro should round trip through arrow")
+
+	// the result should encode with the original codec.
+	_, err = codec.BinaryFromNative(nil, result)
+	assert.Nil(t, err, "the round tripped record should encode to avro")
+}
+
+const testMapValuesSchema = `{
+	"type": "record",
+	"name": "balances",
+	"fields": [
+		{"name": "optional", "type": {"type": "map", "values": ["null", "double"]}},
+		{"name": "references", "type": {"type": "map", "values": ["null", "string", "long"]}},
+		{"name": "products", "type": {"type": "map", "values": {"type": "enum", "name": "product", "symbols": ["CARD", "LOAN"]}}},
+		{"name": "nested", "type": {"type": "map", "values": {"type": "map", "values": ["null", "int"]}}}
+	]
+}`
+
+func TestSchemaFromAvro_map_values_round_trip(t *testing.T) {
+	codec, err := goavro.NewCodec(testMapValuesSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := SchemaFromAvro(testMapValuesSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	native := map[string]interface{}{
+		"optional": map[string]interface{}{"checking": goavro.Union("double", 100.0), "savings": nil},
+		"references": map[string]interface{}{"a": goavro.Union("string", "x"), "b": goavro.Union("long", 7)},
+		"products": map[string]interface{}{"current": "CARD"},
+		"nested": map[string]interface{}{"outer": map[string]interface{}{"inner": goavro.Union("int", 1)}},
+	}
+	binary, err := codec.BinaryFromNative(nil, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datum, _, err := codec.NativeFromBinary(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run the test.
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	record, err := conv.MapToArrowWithSchema(datum.(map[string]interface{}), schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := conv.ArrowToMap(record)
+	assert.Nil(t, err, "there should be no error converting map values from arrow to map")
+	assert.Equal(t, datum, result, "unions and enums in maps should round trip through arrow")
+	_, err = codec.BinaryFromNative(nil, result)
+	assert.Nil(t, err, "the round tripped record should encode to avro")
+
+	// enum symbols are checked for map values too.
+	invalid := datum.(map[string]interface{})
+	invalid["products"] = map[string]interface{}{"current": "BOAT"}
+	_, err = conv.MapToArrowWithSchema(invalid, schema)
+	assert.NotNil(t, err, "there should be an error converting a map value that is not an enum symbol")
+}
+
+func TestSchemaFromAvro_err(t *testing.T) {
+	schemas := []string{
+		`"string"`,
+		`{"type": "record", "name": "a", "fields": [{"name": "b", "type": "Unknown"}]}`,
+		`{"type": "record", "name": "a", "fields": [{"name": "b", "type": ["null", ["int", "string"]]}]}`,
+		`not json`,
+	}
+	for _, s := range schemas {
+		schema, err := SchemaFromAvro(s)
+		assert.Nil(t, schema, "schema should be nil when an error occurred")
+		assert.NotNil(t, err, "there should be an error for schema %s", s)
+	}
+}
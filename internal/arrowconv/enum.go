@@ -0,0 +1,45 @@
+package arrowconv
+
+import (
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"strings"
+)
+
+// Arrow go v7 has no dictionary arrays, so avro enums are stored as string
+// columns with the enum's symbols kept in the field metadata. Values are
+// checked against the symbols in both directions.
+const enumMetadataKey = "avro.enum.symbols"
+
+func enumMetadata(symbols []string) arrow.Metadata {
+	return arrow.NewMetadata([]string{enumMetadataKey}, []string{strings.Join(symbols, ",")})
+}
+
+// enumSymbols returns the symbols of a field holding an avro enum.
+func enumSymbols(field arrow.Field) ([]string, bool) {
+	i := field.Metadata.FindKey(enumMetadataKey)
+	if i < 0 {
+		return nil, false
+	}
+	return strings.Split(field.Metadata.Values()[i], ","), true
+}
+
+func validateEnum(field arrow.Field, val string) error {
+	symbols, ok := enumSymbols(field)
+	if !ok {
+		return nil
+	}
+	for _, symbol := range symbols {
+		if symbol == val {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not a symbol of enum field %s", val, field.Name)
+}
+
+// mergeMetadata combines the keys of both sets of metadata.
+func mergeMetadata(a, b arrow.Metadata) arrow.Metadata {
+	keys := append(append([]string{}, a.Keys()...), b.Keys()...)
+	values := append(append([]string{}, a.Values()...), b.Values()...)
+	return arrow.NewMetadata(keys, values)
+}
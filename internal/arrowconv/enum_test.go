@@ -0,0 +1,116 @@
+package arrowconv
+
+import (
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const testEnumSchema = `{
+	"type": "record",
+	"name": "application",
+	"fields": [
+		{"name": "product_type", "type": {"type": "enum", "name": "product", "symbols": ["CARD", "LOAN", "MORTGAGE"]}},
+		{"name": "previous_product", "type": ["null", "product"]},
+		{"name": "products", "type": {"type": "array", "items": "product"}},
+		{"name": "fingerprint", "type": {"type": "fixed", "name": "md5", "size": 16}}
+	]
+}`
+
+func TestSchemaFromAvro_enum_fixed(t *testing.T) {
+	schema, err := SchemaFromAvro(testEnumSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// verify
+	product := schema.Field(0)
+	assert.Equal(t, arrow.STRING, product.Type.ID(), "an enum must be a string")
+	symbols, ok := enumSymbols(product)
+	assert.True(t, ok, "an enum must keep its symbols in the field metadata")
+	assert.Equal(t, []string{"CARD", "LOAN", "MORTGAGE"}, symbols, "the enum symbols must be in order")
+
+	previous := schema.Field(1)
+	assert.True(t, previous.Nullable, "a union with null must be nullable")
+	_, ok = enumSymbols(previous)
+	assert.True(t, ok, "a nullable enum must keep its symbols in the field metadata")
+
+	products := schema.Field(2).Type.(*arrow.ListType)
+	_, ok = enumSymbols(products.ElemField())
+	assert.True(t, ok, "an array of enums must keep the symbols on its elements")
+
+	assert.Equal(t, &arrow.FixedSizeBinaryType{ByteWidth: 16}, schema.Field(3).Type,
+		"a fixed must be a fixed size binary of the same size")
+}
+
+func TestSchemaFromAvro_enum_fixed_round_trip(t *testing.T) {
+	codec, err := goavro.NewCodec(testEnumSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := SchemaFromAvro(testEnumSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	native := map[string]interface{}{
+		"product_type": "LOAN",
+		"previous_product": goavro.Union("product", "CARD"),
+		"products": []interface{}{"CARD", "MORTGAGE"},
+		"fingerprint": []byte("0123456789abcdef"),
+	}
+	binary, err := codec.BinaryFromNative(nil, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datum, _, err := codec.NativeFromBinary(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run the test.
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	record, err := conv.MapToArrowWithSchema(datum.(map[string]interface{}), schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := conv.ArrowToMap(record)
+	assert.Nil(t, err, "there should be no error converting enums and fixed from arrow to map")
+	assert.Equal(t, datum, result, "enums and fixed should round trip through arrow")
+}
+
+func TestArrowConverter_MapToArrowWithSchema_enum_err(t *testing.T) {
+	schema, err := SchemaFromAvro(testEnumSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []map[string]interface{}{
+		{"product_type": "BOAT", "previous_product": nil, "products": []interface{}{}, "fingerprint": []byte("0123456789abcdef")},
+		{"product_type": "CARD", "previous_product": nil, "products": []interface{}{}, "fingerprint": []byte("short")},
+	}
+
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	for _, data := range rows {
+		result, err := conv.MapToArrowWithSchema(data, schema)
+		if err == nil {
+			defer result.Release()
+		}
+		assert.NotNil(t, err, "there should be an error converting an invalid enum or fixed value")
+	}
+}
+
+func TestArrowConverter_ArrowToMap_enum_err(t *testing.T) {
+	field := arrow.Field{Name: "product_type", Type: &arrow.StringType{}, Metadata: enumMetadata([]string{"CARD", "LOAN"})}
+	schema := arrow.NewSchema([]arrow.Field{field}, nil)
+	builder := array.NewRecordBuilder(memory.NewGoAllocator(), schema)
+	defer builder.Release()
+	builder.Field(0).(*array.StringBuilder).Append("BOAT")
+	record := builder.NewRecord()
+
+	// run the test.
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	_, err := conv.ArrowToMap(record)
+	assert.NotNil(t, err, "there should be an error reading a value that is not an enum symbol")
+}
@@ -0,0 +1,110 @@
+package arrowconv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/decimal128"
+	"math/big"
+	"time"
+)
+
+// maxDecimalPrecision is the most digits a decimal128 can hold.
+const maxDecimalPrecision = 38
+
+// parseLogical returns the arrow type for an avro logical type along with the
+// name goavro uses for it as a union branch. ok is false for logical types
+// goavro doesn't decode, which are treated as their underlying type.
+func (p *avroParser) parseLogical(typeName, logicalType string, schema map[string]interface{},
+	namespace string) (dtype arrow.DataType, branch string, ok bool, err error) {
+	branch = typeName + "." + logicalType
+	switch branch {
+	case "int.date":
+		return arrow.FixedWidthTypes.Date32, branch, true, nil
+	case "int.time-millis":
+		return &arrow.Time32Type{Unit: arrow.Millisecond}, branch, true, nil
+	case "long.time-micros":
+		return &arrow.Time64Type{Unit: arrow.Microsecond}, branch, true, nil
+	case "long.timestamp-millis":
+		return &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}, branch, true, nil
+	case "long.timestamp-micros":
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}, branch, true, nil
+	case "bytes.decimal":
+		dtype, err := decimalType(schema)
+		return dtype, branch, true, err
+	case "fixed.decimal":
+		dtype, err := decimalType(schema)
+		if err != nil {
+			return nil, "", true, err
+		}
+		fullName, _, err := p.fullName(schema, namespace)
+		if err != nil {
+			return nil, "", true, err
+		}
+		p.named[fullName] = dtype
+		return dtype, fullName, true, nil
+	default:
+		// uuid is decoded as a plain string, and so are any logical types
+		// goavro doesn't know about.
+		return nil, "", false, nil
+	}
+}
+
+func decimalType(schema map[string]interface{}) (*arrow.Decimal128Type, error) {
+	precision, ok := schema["precision"].(float64)
+	if !ok {
+		return nil, errors.New("decimal has no precision")
+	}
+	scale, _ := schema["scale"].(float64)
+	if precision > maxDecimalPrecision {
+		return nil, fmt.Errorf("decimal precision %d is larger than %d", int(precision), maxDecimalPrecision)
+	}
+	return &arrow.Decimal128Type{Precision: int32(precision), Scale: int32(scale)}, nil
+}
+
+func timestampFromTime(t time.Time, unit arrow.TimeUnit) arrow.Timestamp {
+	// avoid UnixNano so that dates far from the epoch don't overflow.
+	switch unit {
+	case arrow.Second:
+		return arrow.Timestamp(t.Unix())
+	case arrow.Millisecond:
+		return arrow.Timestamp(t.Unix()*1e3 + int64(t.Nanosecond()/1e6))
+	case arrow.Microsecond:
+		return arrow.Timestamp(t.Unix()*1e6 + int64(t.Nanosecond()/1e3))
+	default:
+		return arrow.Timestamp(t.UnixNano())
+	}
+}
+
+func timeFromTimestamp(ts arrow.Timestamp, unit arrow.TimeUnit) time.Time {
+	perSecond := int64(time.Second / unit.Multiplier())
+	seconds := int64(ts) / perSecond
+	remainder := int64(ts) - seconds*perSecond
+	return time.Unix(seconds, remainder*int64(unit.Multiplier())).UTC()
+}
+
+func date32FromTime(t time.Time) arrow.Date32 {
+	return arrow.Date32(t.Unix() / 86400)
+}
+
+func timeFromDate32(d arrow.Date32) time.Time {
+	return time.Unix(int64(d)*86400, 0).UTC()
+}
+
+func decimalFromRat(r *big.Rat, dtype *arrow.Decimal128Type) (decimal128.Num, error) {
+	scaled := new(big.Int).Mul(r.Num(), pow10(dtype.Scale))
+	scaled.Quo(scaled, r.Denom())
+	if scaled.CmpAbs(pow10(dtype.Precision)) >= 0 {
+		return decimal128.Num{}, fmt.Errorf("%s does not fit in decimal(%d, %d)",
+			r.FloatString(int(dtype.Scale)), dtype.Precision, dtype.Scale)
+	}
+	return decimal128.FromBigInt(scaled), nil
+}
+
+func ratFromDecimal(n decimal128.Num, dtype *arrow.Decimal128Type) *big.Rat {
+	return new(big.Rat).SetFrac(n.BigInt(), pow10(dtype.Scale))
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
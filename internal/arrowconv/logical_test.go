@@ -0,0 +1,106 @@
+package arrowconv
+
+import (
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+const testLogicalSchema = `{
+	"type": "record",
+	"name": "application",
+	"fields": [
+		{"name": "id", "type": {"type": "string", "logicalType": "uuid"}},
+		{"name": "submitted_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "updated_at", "type": ["null", {"type": "long", "logicalType": "timestamp-micros"}]},
+		{"name": "birth_date", "type": {"type": "int", "logicalType": "date"}},
+		{"name": "cutoff", "type": {"type": "int", "logicalType": "time-millis"}},
+		{"name": "window", "type": {"type": "long", "logicalType": "time-micros"}},
+		{"name": "income", "type": {"type": "bytes", "logicalType": "decimal", "precision": 12, "scale": 2}},
+		{"name": "limit", "type": {"type": "fixed", "name": "money", "size": 8, "logicalType": "decimal", "precision": 10, "scale": 2}}
+	]
+}`
+
+func TestSchemaFromAvro_logical(t *testing.T) {
+	schema, err := SchemaFromAvro(testLogicalSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// verify
+	assert.Equal(t, arrow.STRING, schema.Field(0).Type.ID(), "a uuid must be a string")
+	assert.Equal(t, &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}, schema.Field(1).Type,
+		"timestamp-millis must be a millisecond timestamp")
+	assert.Equal(t, &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}, schema.Field(2).Type,
+		"timestamp-micros must be a microsecond timestamp")
+	assert.True(t, schema.Field(2).Nullable, "a union with null must be nullable")
+	assert.Equal(t, arrow.DATE32, schema.Field(3).Type.ID(), "a date must be a date32")
+	assert.Equal(t, &arrow.Time32Type{Unit: arrow.Millisecond}, schema.Field(4).Type, "time-millis must be a time32")
+	assert.Equal(t, &arrow.Time64Type{Unit: arrow.Microsecond}, schema.Field(5).Type, "time-micros must be a time64")
+	assert.Equal(t, &arrow.Decimal128Type{Precision: 12, Scale: 2}, schema.Field(6).Type,
+		"a bytes decimal must keep its precision and scale")
+	assert.Equal(t, &arrow.Decimal128Type{Precision: 10, Scale: 2}, schema.Field(7).Type,
+		"a fixed decimal must keep its precision and scale")
+}
+
+func TestSchemaFromAvro_logical_round_trip(t *testing.T) {
+	codec, err := goavro.NewCodec(testLogicalSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := SchemaFromAvro(testLogicalSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	native := map[string]interface{}{
+		"id": "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"submitted_at": time.Date(2021, 11, 16, 15, 54, 54, 123000000, time.UTC),
+		"updated_at": goavro.Union("long.timestamp-micros", time.Date(2021, 11, 17, 1, 2, 3, 456789000, time.UTC)),
+		"birth_date": time.Date(1980, 5, 17, 0, 0, 0, 0, time.UTC),
+		"cutoff": 17*time.Hour + 30*time.Minute,
+		"window": 90*time.Second + 250*time.Microsecond,
+		"income": big.NewRat(12345678, 100),
+		"limit": big.NewRat(-500000, 100),
+	}
+	binary, err := codec.BinaryFromNative(nil, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datum, _, err := codec.NativeFromBinary(binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run the test.
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	record, err := conv.MapToArrowWithSchema(datum.(map[string]interface{}), schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := conv.ArrowToMap(record)
+	assert.Nil(t, err, "there should be no error converting logical types from arrow to map")
+	encoded, err := codec.BinaryFromNative(nil, result)
+	assert.Nil(t, err, "the round tripped record should encode to avro")
+	assert.Equal(t, binary, encoded, "logical types should round trip through arrow")
+	assert.True(t, native["submitted_at"].(time.Time).Equal(result["submitted_at"].(time.Time)),
+		"timestamps should keep their value")
+	assert.Equal(t, 0, native["income"].(*big.Rat).Cmp(result["income"].(*big.Rat)), "decimals should keep their value")
+}
+
+func TestArrowConverter_MapToArrowWithSchema_decimal_overflow(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "income", Type: &arrow.Decimal128Type{Precision: 4, Scale: 2}},
+	}, nil)
+	data := map[string]interface{}{"income": big.NewRat(12345, 1)}
+
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	result, err := conv.MapToArrowWithSchema(data, schema)
+	if err == nil {
+		defer result.Release()
+	}
+	assert.NotNil(t, err, "there should be an error converting a decimal that doesn't fit its precision")
+}
@@ -0,0 +1,259 @@
+package arrowconv
+
+import (
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"strings"
+)
+
+// Avro unions are stored as arrow fields that carry the union's branch names
+// in their metadata. Arrow go v7 has no union arrays, so
+//   - a union of null and a single other type becomes a nullable field of
+//     that type, and
+//   - a union with several non-null branches becomes a struct with one
+//     nullable child per branch, exactly one of which is set in each row,
+//     mirroring the layout of a sparse arrow union.
+// On the way back the value is wrapped in the single entry map goavro expects.
+const (
+	unionMetadataKey = "avro.union"
+	avroNull = "null"
+)
+
+// primitiveBranches are the union branch names that can be recognised from a
+// decoded value alone. named types can't be told apart from nested records.
+var primitiveBranches = map[string]bool{
+	"boolean": true,
+	"int": true,
+	"long": true,
+	"float": true,
+	"double": true,
+	"bytes": true,
+	"string": true,
+	"array": true,
+}
+
+// UnionBranch is one branch of an avro union. Name is the key goavro uses for
+// the branch: the primitive type name, "array", "map" or the full name of a
+// named type. Metadata is kept on the field that stores the branch.
+type UnionBranch struct {
+	Name string
+	Type arrow.DataType
+	Metadata arrow.Metadata
+}
+
+// NewUnionField builds the arrow field used to store an avro union.
+func NewUnionField(name string, branches []UnionBranch) (arrow.Field, error) {
+	names := make([]string, 0, len(branches))
+	nonNull := make([]UnionBranch, 0, len(branches))
+	for _, branch := range branches {
+		if strings.Contains(branch.Name, ",") {
+			return arrow.Field{}, fmt.Errorf("invalid union branch name: %s", branch.Name)
+		}
+		names = append(names, branch.Name)
+		if branch.Name != avroNull {
+			nonNull = append(nonNull, branch)
+		}
+	}
+	field := arrow.Field{
+		Name: name,
+		Nullable: len(nonNull) != len(branches),
+		Metadata: arrow.NewMetadata([]string{unionMetadataKey}, []string{strings.Join(names, ",")}),
+	}
+	switch len(nonNull) {
+	case 0:
+		field.Type = arrow.Null
+	case 1:
+		field.Type = nonNull[0].Type
+		field.Metadata = mergeMetadata(field.Metadata, nonNull[0].Metadata)
+	default:
+		children := make([]arrow.Field, 0, len(nonNull))
+		for _, branch := range nonNull {
+			child := arrow.Field{Name: branch.Name, Type: branch.Type, Nullable: true, Metadata: branch.Metadata}
+			children = append(children, child)
+		}
+		field.Type = arrow.StructOf(children...)
+	}
+	return field, nil
+}
+
+// unionBranches returns the branch names of a field created by NewUnionField.
+func unionBranches(field arrow.Field) ([]string, bool) {
+	i := field.Metadata.FindKey(unionMetadataKey)
+	if i < 0 {
+		return nil, false
+	}
+	return strings.Split(field.Metadata.Values()[i], ","), true
+}
+
+// unwrapPrimitiveUnion recognises goavro's representation of a union value
+// whose branch is a primitive type or an array.
+func unwrapPrimitiveUnion(val interface{}) (string, interface{}, bool) {
+	wrapper, ok := val.(map[string]interface{})
+	if !ok || len(wrapper) != 1 {
+		return "", nil, false
+	}
+	for branch, inner := range wrapper {
+		if primitiveBranches[branch] {
+			return branch, inner, true
+		}
+	}
+	return "", nil, false
+}
+
+// withoutUnion returns the field describing the single non-null branch of a
+// union field.
+func withoutUnion(field arrow.Field) arrow.Field {
+	keys := make([]string, 0, field.Metadata.Len())
+	values := make([]string, 0, field.Metadata.Len())
+	for i, key := range field.Metadata.Keys() {
+		if key != unionMetadataKey {
+			keys = append(keys, key)
+			values = append(values, field.Metadata.Values()[i])
+		}
+	}
+	return arrow.Field{
+		Name: field.Name,
+		Type: field.Type,
+		Nullable: field.Nullable,
+		Metadata: arrow.NewMetadata(keys, values),
+	}
+}
+
+func countNonNull(branches []string) int {
+	n := 0
+	for _, branch := range branches {
+		if branch != avroNull {
+			n++
+		}
+	}
+	return n
+}
+
+func hasBranch(branches []string, name string) bool {
+	for _, branch := range branches {
+		if branch == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ArrowConverter) appendUnion(builder array.Builder, field arrow.Field, branches []string, val interface{}) error {
+	if val == nil {
+		if !hasBranch(branches, avroNull) {
+			return fmt.Errorf("union field %s has no null branch", field.Name)
+		}
+		builder.AppendNull()
+		return nil
+	}
+	wrapper, ok := val.(map[string]interface{})
+	if !ok || len(wrapper) != 1 {
+		return fmt.Errorf("could not convert %T to a union value for field %s", val, field.Name)
+	}
+	for branch, inner := range wrapper {
+		if branch == avroNull || !hasBranch(branches, branch) {
+			return fmt.Errorf("union field %s has no branch %s", field.Name, branch)
+		}
+		if countNonNull(branches) == 1 {
+			return c.appendValue(builder, withoutUnion(field), inner)
+		}
+		sb := builder.(*array.StructBuilder)
+		sb.Append(true)
+		for i, child := range field.Type.(*arrow.StructType).Fields() {
+			if child.Name != branch {
+				sb.FieldBuilder(i).AppendNull()
+				continue
+			}
+			err := c.appendValue(sb.FieldBuilder(i), child, inner)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *ArrowConverter) readUnion(column array.Interface, field arrow.Field, branches []string, row int) (interface{}, error) {
+	switch countNonNull(branches) {
+	case 0:
+		return nil, nil
+	case 1:
+		val, err := c.readValue(column, withoutUnion(field), row)
+		if err != nil {
+			return nil, err
+		}
+		for _, branch := range branches {
+			if branch != avroNull {
+				return map[string]interface{}{branch: val}, nil
+			}
+		}
+	}
+	col, ok := column.(*array.Struct)
+	if !ok {
+		return nil, fmt.Errorf("could not convert union column %s to *array.Struct", field.Name)
+	}
+	for i, child := range field.Type.(*arrow.StructType).Fields() {
+		if col.Field(i).IsNull(row) {
+			continue
+		}
+		val, err := c.readValue(col.Field(i), child, row)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{child.Name: val}, nil
+	}
+	return nil, fmt.Errorf("no branch is set for union field %s", field.Name)
+}
+
+// WrapUnions returns a copy of row with the value of each single branch union
+// field wrapped the way goavro expects, so scores that come back as bare
+// values, like those of a static fallback or a model that doesn't describe
+// its unions, can be encoded with the avro schema the arrow schema came from.
+// Nil values and values that are already wrapped are left as they are.
+func WrapUnions(schema *arrow.Schema, row map[string]interface{}) map[string]interface{} {
+	return wrapUnions(schema.Fields(), row)
+}
+
+func wrapUnions(fields []arrow.Field, row map[string]interface{}) map[string]interface{} {
+	wrapped := make(map[string]interface{}, len(row))
+	for key, val := range row {
+		wrapped[key] = val
+	}
+	for _, field := range fields {
+		val, ok := row[field.Name]
+		if !ok || val == nil {
+			continue
+		}
+		wrapped[field.Name] = wrapUnion(field, val)
+	}
+	return wrapped
+}
+
+func wrapUnion(field arrow.Field, val interface{}) interface{} {
+	branches, isUnion := unionBranches(field)
+	if !isUnion {
+		if st, ok := field.Type.(*arrow.StructType); ok {
+			if record, ok := val.(map[string]interface{}); ok {
+				return wrapUnions(st.Fields(), record)
+			}
+		}
+		return val
+	}
+	if wrapper, ok := val.(map[string]interface{}); ok && len(wrapper) == 1 {
+		for branch := range wrapper {
+			if hasBranch(branches, branch) {
+				return val
+			}
+		}
+	}
+	if countNonNull(branches) != 1 {
+		return val
+	}
+	for _, branch := range branches {
+		if branch != avroNull {
+			return map[string]interface{}{branch: wrapUnion(withoutUnion(field), val)}
+		}
+	}
+	return val
+}
@@ -0,0 +1,130 @@
+package arrowconv
+
+import (
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func getUnionTestSchema(t *testing.T) *arrow.Schema {
+	balance, err := NewUnionField("balance", []UnionBranch{
+		{Name: "null"},
+		{Name: "double", Type: &arrow.Float64Type{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference, err := NewUnionField("reference", []UnionBranch{
+		{Name: "null"},
+		{Name: "string", Type: &arrow.StringType{}},
+		{Name: "long", Type: &arrow.Int64Type{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return arrow.NewSchema([]arrow.Field{balance, reference}, nil)
+}
+
+func TestNewUnionField(t *testing.T) {
+	schema := getUnionTestSchema(t)
+
+	// verify
+	balance := schema.Field(0)
+	assert.True(t, balance.Nullable, "a union with null should be nullable")
+	assert.Equal(t, arrow.FLOAT64, balance.Type.ID(), "a union with one other branch should use that type")
+
+	reference := schema.Field(1)
+	assert.True(t, reference.Nullable, "a union with null should be nullable")
+	assert.Equal(t, arrow.STRUCT, reference.Type.ID(), "a union with several branches should be a struct")
+	referenceType := reference.Type.(*arrow.StructType)
+	assert.Equal(t, 2, len(referenceType.Fields()), "there should be one child per non-null branch")
+	assert.True(t, referenceType.Field(0).Nullable, "branch children must be nullable")
+}
+
+func TestArrowConverter_MapToArrowWithSchema_union(t *testing.T) {
+	schema := getUnionTestSchema(t)
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	rows := []map[string]interface{}{
+		{"balance": map[string]interface{}{"double": float64(10.5)}, "reference": map[string]interface{}{"string": "abc"}},
+		{"balance": nil, "reference": map[string]interface{}{"long": int64(42)}},
+		{"balance": nil, "reference": nil},
+	}
+
+	for _, data := range rows {
+		record, err := conv.MapToArrowWithSchema(data, schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// run the test.
+		result, err := conv.ArrowToMap(record)
+		assert.Nil(t, err, "there should be no error converting unions from arrow to map")
+		assert.Equal(t, data, result, "unions should round trip through arrow")
+	}
+}
+
+func TestArrowConverter_MapToArrowWithSchema_union_err(t *testing.T) {
+	schema := getUnionTestSchema(t)
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	rows := []map[string]interface{}{
+		{"balance": map[string]interface{}{"int": int32(10)}, "reference": nil},
+		{"balance": float64(10.5), "reference": nil},
+	}
+
+	for _, data := range rows {
+		result, err := conv.MapToArrowWithSchema(data, schema)
+		if err == nil {
+			defer result.Release()
+		}
+		assert.NotNil(t, err, "there should be an error converting a value that matches no union branch")
+	}
+}
+
+func TestArrowConverter_MapToArrow_union(t *testing.T) {
+	data := make(map[string]interface{})
+	data["app_id"] = "1000"
+	data["balance"] = map[string]interface{}{"double": float64(10.5)}
+	data["missing"] = nil
+
+	conv := NewArrowConverter(memory.NewGoAllocator())
+	schema, err := conv.getSchema(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance, _ := schema.FieldsByName("balance")
+	assert.True(t, balance[0].Nullable, "a wrapped union value should give a nullable field")
+	assert.Equal(t, arrow.FLOAT64, balance[0].Type.ID(), "a wrapped union value should be unwrapped")
+	missing, _ := schema.FieldsByName("missing")
+	assert.True(t, missing[0].Nullable, "a nil value should give a nullable field")
+
+	// run the test.
+	record, err := conv.MapToArrow(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := conv.ArrowToMap(record)
+	assert.Nil(t, err, "there should be no error converting unions from arrow to map")
+	assert.Equal(t, data, result, "unions should round trip through arrow")
+}
+
+func TestWrapUnions(t *testing.T) {
+	schema := getUnionTestSchema(t)
+	row := map[string]interface{}{
+		"balance": 10.5,
+		"reference": map[string]interface{}{"long": int64(7)},
+		"extra": "kept",
+	}
+
+	// run the test
+	wrapped := WrapUnions(schema, row)
+
+	// verify
+	assert.Equal(t, map[string]interface{}{"double": 10.5}, wrapped["balance"],
+		"a bare value should be wrapped in its only non-null branch")
+	assert.Equal(t, row["reference"], wrapped["reference"], "a wrapped value should be left as it is")
+	assert.Equal(t, "kept", wrapped["extra"], "values without a field should be kept")
+	assert.Equal(t, 10.5, row["balance"], "the row itself should not be changed")
+	assert.Nil(t, WrapUnions(schema, map[string]interface{}{"balance": nil})["balance"],
+		"a nil value should stay nil")
+}
@@ -4,18 +4,25 @@ import (
 	"context"
 	"fmt"
 	"github.com/ReneKroon/ttlcache/v2"
+	"github.com/apache/arrow/go/v7/arrow"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/ehenry2/avro-flight-decisioner/internal/arrowconv"
 	"github.com/linkedin/goavro/v2"
 	"io/ioutil"
 	"log"
+	"sync"
 )
 
 type S3Client interface {
 	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
 }
 
+// AvroCodecLoader resolves the avro schema for a CloudEvent type. Fetching a
+// schema that isn't cached is abandoned once the context is done.
 type AvroCodecLoader interface {
-	LoadCodec(string) (*goavro.Codec, error)
+	LoadCodec(context.Context, string) (*goavro.Codec, error)
+	// LoadSchema returns the codec along with the arrow schema derived from it.
+	LoadSchema(context.Context, string) (*goavro.Codec, *arrow.Schema, error)
 }
 
 type S3AvroCodecLoader struct {
@@ -23,6 +30,13 @@ type S3AvroCodecLoader struct {
 	storageClient S3Client
 	bucketName string
 	objectPrefix string
+	// codecs holds a *goavro.Codec and arrowSchemas an *arrow.Schema per schema
+	// definition, so each distinct schema is only parsed once however often its
+	// cache entry expires. Arrow schemas are only derived for the features and
+	// scores records, since some valid avro, such as a schema that isn't a
+	// record, has no arrow equivalent.
+	codecs sync.Map
+	arrowSchemas sync.Map
 }
 
 func (l *S3AvroCodecLoader) getSchemaFromCache(cloudEventName string) (string, bool) {
@@ -43,13 +57,13 @@ func (l *S3AvroCodecLoader) getSchemaFromCache(cloudEventName string) (string, b
 	return s, ok
 }
 
-func (l *S3AvroCodecLoader) loadSchemaFromS3(cloudEventName string) (string, error) {
+func (l *S3AvroCodecLoader) loadSchemaFromS3(ctx context.Context, cloudEventName string) (string, error) {
 	key := fmt.Sprintf("%s/%s.json", l.objectPrefix, cloudEventName)
 	input := &s3.GetObjectInput{
 		Bucket: &l.bucketName,
 		Key: &key,
 	}
-	output, err := l.storageClient.GetObject(context.Background(), input)
+	output, err := l.storageClient.GetObject(ctx, input)
 	if err != nil {
 		return "", err
 	}
@@ -60,24 +74,69 @@ func (l *S3AvroCodecLoader) loadSchemaFromS3(cloudEventName string) (string, err
 	return string(b), nil
 }
 
-func (l *S3AvroCodecLoader) LoadCodec(cloudEventName string) (*goavro.Codec, error) {
+func (l *S3AvroCodecLoader) loadSchemaDefinition(ctx context.Context, cloudEventName string) (string, error) {
 	s, found := l.getSchemaFromCache(cloudEventName)
-	if !found {
-		s, err := l.loadSchemaFromS3(cloudEventName)
-		if err != nil {
-			return nil, err
-		}
-		// set it in the cache.
-		err = l.cache.Set(cloudEventName, s)
-		if err != nil {
-			log.Printf("error setting key in cache: key: %s, reason %s", cloudEventName, err)
-		}
-		return goavro.NewCodec(s)
+	if found {
+		return s, nil
+	}
+	s, err := l.loadSchemaFromS3(ctx, cloudEventName)
+	if err != nil {
+		return "", err
+	}
+	// set it in the cache.
+	err = l.cache.Set(cloudEventName, s)
+	if err != nil {
+		log.Printf("error setting key in cache: key: %s, reason %s", cloudEventName, err)
+	}
+	return s, nil
+}
+
+func (l *S3AvroCodecLoader) LoadSchema(ctx context.Context, cloudEventName string) (*goavro.Codec, *arrow.Schema, error) {
+	s, err := l.loadSchemaDefinition(ctx, cloudEventName)
+	if err != nil {
+		return nil, nil, err
+	}
+	codec, err := l.compileCodec(s)
+	if err != nil {
+		return nil, nil, err
 	}
-	return goavro.NewCodec(s)
+	if arrowSchema, ok := l.arrowSchemas.Load(s); ok {
+		return codec, arrowSchema.(*arrow.Schema), nil
+	}
+	arrowSchema, err := arrowconv.SchemaFromAvro(s)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error deriving arrow schema: event: %s, reason: %w", cloudEventName, err)
+	}
+	stored, _ := l.arrowSchemas.LoadOrStore(s, arrowSchema)
+	return codec, stored.(*arrow.Schema), nil
+}
+
+func (l *S3AvroCodecLoader) LoadCodec(ctx context.Context, cloudEventName string) (*goavro.Codec, error) {
+	s, err := l.loadSchemaDefinition(ctx, cloudEventName)
+	if err != nil {
+		return nil, err
+	}
+	return l.compileCodec(s)
+}
+
+func (l *S3AvroCodecLoader) compileCodec(s string) (*goavro.Codec, error) {
+	if codec, ok := l.codecs.Load(s); ok {
+		return codec.(*goavro.Codec), nil
+	}
+	codec, err := goavro.NewCodec(s)
+	if err != nil {
+		return nil, err
+	}
+	stored, _ := l.codecs.LoadOrStore(s, codec)
+	return stored.(*goavro.Codec), nil
 }
 
 func NewS3AvroCodecLoader(cache ttlcache.SimpleCache, storageClient S3Client,
 	bucketName, objectPrefix string) *S3AvroCodecLoader {
-	return &S3AvroCodecLoader{cache, storageClient, bucketName, objectPrefix}
+	return &S3AvroCodecLoader{
+		cache: cache,
+		storageClient: storageClient,
+		bucketName: bucketName,
+		objectPrefix: objectPrefix,
+	}
 }
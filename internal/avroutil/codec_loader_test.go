@@ -57,7 +57,7 @@ func TestS3AvroCodecLoader_LoadCodec(t *testing.T) {
 	}
 
 	// run the test.
-	codec, err := loader.LoadCodec(eventName)
+	codec, err := loader.LoadCodec(context.Background(), eventName)
 	assert.Nil(t, err, "there should be no error when creating codec")
 
 	// verify by encoding a record.
@@ -97,7 +97,7 @@ func TestS3AvroCodecLoader_LoadCodec_in_cache(t *testing.T) {
 	}
 
 	// run the test.
-	codec, err := loader.LoadCodec(eventName)
+	codec, err := loader.LoadCodec(context.Background(), eventName)
 	assert.Nil(t, err, "there should be no error when creating codec")
 
 	// verify by encoding a record.
@@ -230,7 +230,7 @@ func TestS3AvroCodecLoader_loadSchemaFromS3(t *testing.T) {
 
 	// create loader
 	loader := NewS3AvroCodecLoader(cache, mockClient, bucketName, objectPrefix)
-	result, err := loader.loadSchemaFromS3(eventName)
+	result, err := loader.loadSchemaFromS3(context.Background(), eventName)
 	assert.Nil(t, err, "there should be no error retrieving schema")
 	assert.Equal(t, result, schema, "the schema should be the same as expected")
 }
@@ -262,7 +262,89 @@ func TestS3AvroCodecLoader_loadSchemaFromS3_error(t *testing.T) {
 
 	// create loader
 	loader := NewS3AvroCodecLoader(cache, mockClient, bucketName, objectPrefix)
-	result, err := loader.loadSchemaFromS3(eventName)
+	result, err := loader.loadSchemaFromS3(context.Background(), eventName)
 	assert.NotNil(t, err, "there should be an s3 error retrieving the schema")
 	assert.Equal(t, result, schema, "the schema should be an empty string b/c of error")
-}
\ No newline at end of file
+}
+func TestS3AvroCodecLoader_LoadSchema(t *testing.T) {
+	// configuration
+	eventName := "test.custom"
+	bucketName := "fake-test-bucket"
+	objectPrefix := "fake-prefix"
+	schema := `{"type": "record", "name": "ultra_risk_version_6_19", "fields": [{"name": "col1", "type": "string"}, {"name": "col0", "type": ["null", "long"]}]}`
+
+	// set up mocks.
+	ctrl := gomock.NewController(t)
+	mockS3Client := mocks.NewMockS3Client(ctrl)
+
+	// create cache
+	cache := ttlcache.NewCache()
+	_ = cache.Set(eventName, schema)
+
+	// create loader
+	loader := NewS3AvroCodecLoader(cache, mockS3Client, bucketName, objectPrefix)
+
+	// run the test.
+	codec, arrowSchema, err := loader.LoadSchema(context.Background(), eventName)
+	assert.Nil(t, err, "there should be no error when loading the schema")
+	assert.Equal(t, "col1", arrowSchema.Field(0).Name, "arrow fields should follow the avro field order")
+	assert.Equal(t, "col0", arrowSchema.Field(1).Name, "arrow fields should follow the avro field order")
+	assert.True(t, arrowSchema.Field(1).Nullable, "a union with null should be nullable")
+
+	// verify the compiled schema is reused.
+	codec2, arrowSchema2, err := loader.LoadSchema(context.Background(), eventName)
+	assert.Nil(t, err, "there should be no error when loading the schema again")
+	assert.Same(t, codec, codec2, "the codec should be reused")
+	assert.Same(t, arrowSchema, arrowSchema2, "the arrow schema should be reused")
+}
+
+func TestS3AvroCodecLoader_LoadCodec_no_arrow_equivalent(t *testing.T) {
+	// configuration
+	eventName := "test.scores"
+	schema := `{"type": "map", "values": ["null", "double"]}`
+
+	// set up mocks.
+	ctrl := gomock.NewController(t)
+	mockS3Client := mocks.NewMockS3Client(ctrl)
+
+	// create cache
+	cache := ttlcache.NewCache()
+	_ = cache.Set(eventName, schema)
+
+	// create loader
+	loader := NewS3AvroCodecLoader(cache, mockS3Client, "fake-test-bucket", "fake-prefix")
+
+	// run the test.
+	codec, err := loader.LoadCodec(context.Background(), eventName)
+	assert.Nil(t, err, "a codec should load even when the schema has no arrow equivalent")
+	assert.NotNil(t, codec)
+	_, _, err = loader.LoadSchema(context.Background(), eventName)
+	assert.Error(t, err, "the arrow schema can't be derived")
+}
+
+func TestS3AvroCodecLoader_LoadCodec_cancelled(t *testing.T) {
+	// configuration
+	eventName := "test.custom"
+	bucketName := "fake-test-bucket"
+	objectPrefix := "fake-prefix"
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// set up mocks, the fake s3 call blocks until the request is cancelled.
+	ctrl := gomock.NewController(t)
+	mockS3Client := mocks.NewMockS3Client(ctrl)
+	mockS3Client.EXPECT().
+		GetObject(gomock.Eq(ctx), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+	// create loader
+	loader := NewS3AvroCodecLoader(ttlcache.NewCache(), mockS3Client, bucketName, objectPrefix)
+
+	// run the test.
+	time.AfterFunc(10*time.Millisecond, cancel)
+	codec, err := loader.LoadCodec(ctx, eventName)
+	assert.Nil(t, codec, "there should be no codec when the request is cancelled")
+	assert.ErrorIs(t, err, context.Canceled, "cancelling the request should abort the s3 call")
+}
@@ -0,0 +1,321 @@
+// Package config loads the decisioner's settings. Defaults are overridden by
+// an optional YAML file named by CONFIG_FILE, which is in turn overridden by
+// individual environment variables, so one image can be configured per
+// environment by its Knative service alone.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+type Config struct {
+	// Port is the port the CloudEvents receiver listens on. Knative sets PORT.
+	Port int `yaml:"port"`
+	Health HealthConfig `yaml:"health"`
+	Schema SchemaConfig `yaml:"schema"`
+	Flight FlightConfig `yaml:"flight"`
+	Response ResponseConfig `yaml:"response"`
+	Timeouts TimeoutConfig `yaml:"timeouts"`
+	Batching BatchingConfig `yaml:"batching"`
+	Retry RetryConfig `yaml:"retry"`
+	Breaker BreakerConfig `yaml:"breaker"`
+	Fallback FallbackConfig `yaml:"fallback"`
+	// Routes map event types to models. With no routes every event is scored
+	// by the single model served at the flight endpoints.
+	Routes []RouteConfig `yaml:"routes"`
+	// Split divides the default model's traffic between versions. It can't be
+	// combined with routes.
+	Split SplitConfig `yaml:"split"`
+	Shadow ShadowConfig `yaml:"shadow"`
+}
+
+// SchemaConfig locates the avro schemas, stored in S3 as <prefix>/<event type>.json.
+type SchemaConfig struct {
+	Bucket string `yaml:"bucket"`
+	Prefix string `yaml:"prefix"`
+	CacheTTL time.Duration `yaml:"cacheTTL"`
+}
+
+// HealthConfig serves liveness and readiness on their own port, away from the
+// events. Readiness loads the schema for ProbeEventType, the response event
+// type if empty, to check the schema store is reachable.
+type HealthConfig struct {
+	Port int `yaml:"port"`
+	ProbeEventType string `yaml:"probeEventType"`
+}
+
+// FlightConfig connects to the model servers. Endpoints are replicas of the
+// default model, and calls are spread over them by Balancing, round_robin or
+// least_request. Any endpoint, here or in a route, may instead be a gRPC
+// target such as dns:///model-server:9998, balancing over every address the
+// name resolves to.
+//
+// Scoring reuses exchange streams, and a replica is picked per stream, so
+// least_request weighs replicas by their open streams. Streams are retired
+// after StreamMaxRequests requests or StreamMaxAge, zero for no limit, which
+// bounds how long traffic stays on one replica. A StreamMaxRequests of 1
+// picks a replica for every request.
+type FlightConfig struct {
+	Endpoints []string `yaml:"endpoints"`
+	Balancing string `yaml:"balancing"`
+	HealthCheck HealthCheckConfig `yaml:"healthCheck"`
+	TLS TLSConfig `yaml:"tls"`
+	Auth AuthConfig `yaml:"auth"`
+	// ConnectTimeout bounds each attempt to connect to an endpoint. Failed
+	// attempts are retried in the background, backing off up to MaxReconnectBackoff.
+	ConnectTimeout time.Duration `yaml:"connectTimeout"`
+	MaxReconnectBackoff time.Duration `yaml:"maxReconnectBackoff"`
+	Keepalive KeepaliveConfig `yaml:"keepalive"`
+	// DiscoveryInterval is how often each model's advertised schemas are
+	// refreshed after startup. Zero discovers them only at startup.
+	DiscoveryInterval time.Duration `yaml:"discoveryInterval"`
+	StreamMaxRequests int `yaml:"streamMaxRequests"`
+	StreamMaxAge time.Duration `yaml:"streamMaxAge"`
+}
+
+const (
+	BalancingRoundRobin = "round_robin"
+	BalancingLeastRequest = "least_request"
+)
+
+// HealthCheckConfig asks each replica for the grpc.health.v1 status of
+// Service, sending calls only to replicas that are serving. Replicas that
+// don't implement the health service are assumed healthy.
+type HealthCheckConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Service string `yaml:"service"`
+}
+
+// KeepaliveConfig pings the model server after Time without activity,
+// dropping the connection if no answer comes within Timeout. A zero Time
+// disables pings. Pings more often than the server's enforcement policy
+// allows, five minutes by default for gRPC servers, get the connection closed.
+type KeepaliveConfig struct {
+	Time time.Duration `yaml:"time"`
+	Timeout time.Duration `yaml:"timeout"`
+	PermitWithoutStream bool `yaml:"permitWithoutStream"`
+}
+
+// TLSConfig secures the connection to the model server. CertFile and KeyFile
+// present a client certificate for mutual TLS.
+type TLSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	CAFile string `yaml:"caFile"`
+	CertFile string `yaml:"certFile"`
+	KeyFile string `yaml:"keyFile"`
+	ServerName string `yaml:"serverName"`
+}
+
+const (
+	AuthNone = ""
+	AuthBasic = "basic"
+	AuthToken = "token"
+)
+
+// AuthConfig authenticates to the model server, or a proxy in front of it,
+// with a bearer token. Basic exchanges Username and Password for a token with
+// the flight handshake, renewing it after TokenTTL if set. Token sends Token,
+// or the contents of TokenFile, which is reread when it changes. Either way
+// the token is sent to every replica of an endpoint, so each must accept it.
+type AuthConfig struct {
+	Mode string `yaml:"mode"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	TokenTTL time.Duration `yaml:"tokenTTL"`
+	Token string `yaml:"token"`
+	TokenFile string `yaml:"tokenFile"`
+}
+
+type ResponseConfig struct {
+	EventType string `yaml:"eventType"`
+	Source string `yaml:"source"`
+}
+
+// TimeoutConfig bounds each stage of handling an event. Zero means unbounded.
+type TimeoutConfig struct {
+	LoadSchema time.Duration `yaml:"loadSchema"`
+	Score time.Duration `yaml:"score"`
+	// PrimaryScore bounds the model alone, leaving the rest of Score for the fallback.
+	PrimaryScore time.Duration `yaml:"primaryScore"`
+}
+
+type BatchingConfig struct {
+	// Size is the most events scored per model call, 1 disables batching.
+	Size int `yaml:"size"`
+	Wait time.Duration `yaml:"wait"`
+}
+
+type RetryConfig struct {
+	MaxAttempts int `yaml:"maxAttempts"`
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+	MaxBackoff time.Duration `yaml:"maxBackoff"`
+	Multiplier float64 `yaml:"multiplier"`
+}
+
+type BreakerConfig struct {
+	FailureThreshold int `yaml:"failureThreshold"`
+	OpenTimeout time.Duration `yaml:"openTimeout"`
+}
+
+// RouteConfig sends events whose type matches EventType, exactly or as a
+// path.Match pattern, to Model. Endpoint defaults to the flight endpoints,
+// balanced like the default model, and Path, the flight descriptor path, defaults to [Model].
+// Routes can only be set in the config file.
+type RouteConfig struct {
+	EventType string `yaml:"eventType"`
+	Model string `yaml:"model"`
+	Endpoint string `yaml:"endpoint"`
+	Path []string `yaml:"path"`
+}
+
+// SplitConfig splits traffic between weighted variants, assigning each event
+// by hashing either Attribute, a CloudEvent attribute or extension, or Field,
+// a feature. Variants can only be set in the config file.
+type SplitConfig struct {
+	Attribute string `yaml:"attribute"`
+	Field string `yaml:"field"`
+	Variants []VariantConfig `yaml:"variants"`
+}
+
+// VariantConfig is a model version receiving a share of traffic proportional
+// to Weight. Endpoint defaults to the flight endpoints, and Path, the
+// flight descriptor path, defaults to [Name].
+type VariantConfig struct {
+	Name string `yaml:"name"`
+	Weight int `yaml:"weight"`
+	Endpoint string `yaml:"endpoint"`
+	Path []string `yaml:"path"`
+}
+
+// ShadowConfig scores every event against challenger models in the
+// background, recording their scores next to the champion's as JSON lines in
+// Output, or on stdout if Output is empty. Challengers can only be set in the
+// config file.
+type ShadowConfig struct {
+	Challengers []ChallengerConfig `yaml:"challengers"`
+	Output string `yaml:"output"`
+	// Timeout bounds each challenger call.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxInFlight caps concurrent challenger calls, beyond which they are skipped.
+	MaxInFlight int `yaml:"maxInFlight"`
+}
+
+// ChallengerConfig is a challenger model served at Endpoint. Path, the flight
+// descriptor path, defaults to [Name].
+type ChallengerConfig struct {
+	Name string `yaml:"name"`
+	Endpoint string `yaml:"endpoint"`
+	Path []string `yaml:"path"`
+}
+
+const (
+	FallbackNone = ""
+	FallbackStatic = "static"
+	FallbackRules = "rules"
+	FallbackFlight = "flight"
+)
+
+// FallbackConfig selects what scores events when the model can't. Scores is
+// used by static, RulesFile by rules and Endpoint by flight.
+type FallbackConfig struct {
+	Mode string `yaml:"mode"`
+	Scores map[string]interface{} `yaml:"scores"`
+	RulesFile string `yaml:"rulesFile"`
+	Endpoint string `yaml:"endpoint"`
+}
+
+// Default returns the configuration used for anything left unset.
+func Default() Config {
+	return Config{
+		Port: 8080,
+		Health: HealthConfig{
+			Port: 8090,
+		},
+		Schema: SchemaConfig{
+			CacheTTL: 10 * time.Minute,
+		},
+		Flight: FlightConfig{
+			Endpoints: []string{"127.0.0.1:9998"},
+			Balancing: BalancingRoundRobin,
+			HealthCheck: HealthCheckConfig{
+				Enabled: true,
+			},
+			DiscoveryInterval: 5 * time.Minute,
+			StreamMaxRequests: 100,
+			StreamMaxAge: 30 * time.Second,
+			ConnectTimeout: 20 * time.Second,
+			MaxReconnectBackoff: 30 * time.Second,
+			Keepalive: KeepaliveConfig{
+				Timeout: 20 * time.Second,
+			},
+		},
+		Response: ResponseConfig{
+			EventType: "avro-flight-decisioner.decision",
+			Source: "avro-flight-decisioner",
+		},
+		Batching: BatchingConfig{
+			Size: 1,
+			Wait: 5 * time.Millisecond,
+		},
+		Retry: RetryConfig{
+			MaxAttempts: 3,
+			InitialBackoff: 50 * time.Millisecond,
+			MaxBackoff: time.Second,
+			Multiplier: 2,
+		},
+		Breaker: BreakerConfig{
+			FailureThreshold: 5,
+			OpenTimeout: 10 * time.Second,
+		},
+		Shadow: ShadowConfig{
+			Timeout: time.Second,
+			MaxInFlight: 64,
+		},
+	}
+}
+
+// Load builds the configuration from the YAML file named by CONFIG_FILE, if
+// any, and the environment, then validates it.
+func Load() (*Config, error) {
+	return load(os.Getenv)
+}
+
+func load(getenv func(string) string) (*Config, error) {
+	cfg := Default()
+	if path := getenv("CONFIG_FILE"); path != "" {
+		err := readFile(path, &cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := applyEnv(&cfg, getenv)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func readFile(path string, cfg *Config) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	err = decoder.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error parsing config file: file: %s, reason: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testConfigFile = `
+port: 9090
+schema:
+  bucket: schemas-staging
+  prefix: risk
+  cacheTTL: 5m
+flight:
+  endpoints: [model-a:9998, model-b:9998]
+  tls:
+    enabled: true
+    caFile: /etc/certs/ca.pem
+    serverName: model.internal
+timeouts:
+  score: 250ms
+batching:
+  size: 16
+  wait: 2ms
+fallback:
+  mode: static
+  scores:
+    score: 0.5
+routes:
+  - eventType: urm_6.fraud.feature
+    model: fraud-v3
+    endpoint: fraud-model:9998
+  - eventType: urm_6.*.feature
+    model: risk
+    path: [models, risk, v2]
+shadow:
+  output: /var/log/shadow.jsonl
+  challengers:
+    - name: risk-v3
+      endpoint: challenger:9998
+`
+
+// fakeEnv returns a getenv that reads from the map.
+func fakeEnv(vars map[string]string) func(string) string {
+	return func(name string) string {
+		return vars[name]
+	}
+}
+
+func writeConfigFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := ioutil.WriteFile(path, []byte(contents), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_defaults(t *testing.T) {
+	cfg, err := load(fakeEnv(map[string]string{"SCHEMA_BUCKET": "schemas-dev"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Default()
+	expected.Schema.Bucket = "schemas-dev"
+	assert.Equal(t, &expected, cfg, "anything unset should take its default")
+}
+
+func TestLoad_file(t *testing.T) {
+	cfg, err := load(fakeEnv(map[string]string{"CONFIG_FILE": writeConfigFile(t, testConfigFile)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 9090, cfg.Port)
+	assert.Equal(t, SchemaConfig{Bucket: "schemas-staging", Prefix: "risk", CacheTTL: 5 * time.Minute}, cfg.Schema)
+	assert.Equal(t, []string{"model-a:9998", "model-b:9998"}, cfg.Flight.Endpoints)
+	assert.Equal(t, TLSConfig{Enabled: true, CAFile: "/etc/certs/ca.pem", ServerName: "model.internal"}, cfg.Flight.TLS)
+	assert.Equal(t, 250*time.Millisecond, cfg.Timeouts.Score)
+	assert.Equal(t, BatchingConfig{Size: 16, Wait: 2 * time.Millisecond}, cfg.Batching)
+	assert.Equal(t, map[string]interface{}{"score": 0.5}, cfg.Fallback.Scores)
+	assert.Equal(t, []RouteConfig{
+		{EventType: "urm_6.fraud.feature", Model: "fraud-v3", Endpoint: "fraud-model:9998"},
+		{EventType: "urm_6.*.feature", Model: "risk", Path: []string{"models", "risk", "v2"}},
+	}, cfg.Routes)
+	assert.Equal(t, ShadowConfig{
+		Challengers: []ChallengerConfig{{Name: "risk-v3", Endpoint: "challenger:9998"}},
+		Output: "/var/log/shadow.jsonl",
+		Timeout: time.Second,
+		MaxInFlight: 64,
+	}, cfg.Shadow)
+	assert.Equal(t, Default().Retry, cfg.Retry, "sections missing from the file should keep their defaults")
+}
+
+func TestLoad_env_overrides_file(t *testing.T) {
+	cfg, err := load(fakeEnv(map[string]string{
+		"CONFIG_FILE": writeConfigFile(t, testConfigFile),
+		"PORT": "8081",
+		"SCHEMA_BUCKET": "schemas-prod",
+		"SCHEMA_CACHE_TTL_MS": "60000",
+		"FLIGHT_ENDPOINTS": "model-c:9998, model-d:9998",
+		"SCORING_BATCH_SIZE": "1",
+		"FLIGHT_DISCOVERY_INTERVAL_MS": "0",
+		"FLIGHT_KEEPALIVE_TIME_MS": "300000",
+		"FLIGHT_KEEPALIVE_PERMIT_WITHOUT_STREAM": "true",
+		"FLIGHT_BALANCING": "least_request",
+		"FLIGHT_HEALTH_CHECK_ENABLED": "false",
+		"FLIGHT_STREAM_MAX_REQUESTS": "1",
+		"FLIGHT_STREAM_MAX_AGE_MS": "0",
+		"HEALTH_PROBE_EVENT_TYPE": "urm_6.19.feature",
+		"FALLBACK_SCORES": `{"score": 0.7}`,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 8081, cfg.Port)
+	assert.Equal(t, "schemas-prod", cfg.Schema.Bucket)
+	assert.Equal(t, "risk", cfg.Schema.Prefix, "settings without an env var should come from the file")
+	assert.Equal(t, time.Minute, cfg.Schema.CacheTTL)
+	assert.Equal(t, []string{"model-c:9998", "model-d:9998"}, cfg.Flight.Endpoints)
+	assert.Equal(t, 1, cfg.Batching.Size)
+	assert.Equal(t, time.Duration(0), cfg.Flight.DiscoveryInterval)
+	assert.Equal(t, BalancingLeastRequest, cfg.Flight.Balancing)
+	assert.False(t, cfg.Flight.HealthCheck.Enabled)
+	assert.Equal(t, 1, cfg.Flight.StreamMaxRequests)
+	assert.Equal(t, time.Duration(0), cfg.Flight.StreamMaxAge)
+	assert.Equal(t, HealthConfig{Port: 8090, ProbeEventType: "urm_6.19.feature"}, cfg.Health)
+	assert.Equal(t, KeepaliveConfig{Time: 5 * time.Minute, Timeout: 20 * time.Second, PermitWithoutStream: true}, cfg.Flight.Keepalive)
+	assert.Equal(t, map[string]interface{}{"score": 0.7}, cfg.Fallback.Scores)
+}
+
+func TestLoad_split(t *testing.T) {
+	cfg, err := load(fakeEnv(map[string]string{
+		"SCHEMA_BUCKET": "b",
+		"CONFIG_FILE": writeConfigFile(t, `
+split:
+  attribute: subject
+  variants:
+    - {name: risk-v1, weight: 90}
+    - {name: risk-v2, weight: 10, endpoint: "model-v2:9998", path: [risk, v2]}
+`),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, SplitConfig{
+		Attribute: "subject",
+		Variants: []VariantConfig{
+			{Name: "risk-v1", Weight: 90},
+			{Name: "risk-v2", Weight: 10, Endpoint: "model-v2:9998", Path: []string{"risk", "v2"}},
+		},
+	}, cfg.Split)
+}
+
+func TestLoad_auth(t *testing.T) {
+	cfg, err := load(fakeEnv(map[string]string{
+		"SCHEMA_BUCKET": "b",
+		"FLIGHT_AUTH_MODE": "basic",
+		"FLIGHT_AUTH_USERNAME": "decisioner",
+		"FLIGHT_AUTH_PASSWORD": "secret",
+		"FLIGHT_AUTH_TOKEN_TTL_MS": "900000",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, AuthConfig{Mode: AuthBasic, Username: "decisioner", Password: "secret", TokenTTL: 15 * time.Minute}, cfg.Flight.Auth)
+}
+
+func TestLoad_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		env map[string]string
+		file string
+	}{
+		{"missing bucket", map[string]string{}, ""},
+		{"bad int", map[string]string{"SCHEMA_BUCKET": "b", "PORT": "http"}, ""},
+		{"bad bool", map[string]string{"SCHEMA_BUCKET": "b", "FLIGHT_TLS_ENABLED": "maybe"}, ""},
+		{"bad json", map[string]string{"SCHEMA_BUCKET": "b", "FALLBACK_MODE": "static", "FALLBACK_SCORES": "{"}, ""},
+		{"port out of range", map[string]string{"SCHEMA_BUCKET": "b", "PORT": "70000"}, ""},
+		{"target among endpoints", map[string]string{"SCHEMA_BUCKET": "b", "FLIGHT_ENDPOINTS": "dns:///model:9998,model-b:9998"}, ""},
+		{"negative stream requests", map[string]string{"SCHEMA_BUCKET": "b", "FLIGHT_STREAM_MAX_REQUESTS": "-1"}, ""},
+		{"unknown balancing", map[string]string{"SCHEMA_BUCKET": "b", "FLIGHT_BALANCING": "random"}, ""},
+		{"health port clash", map[string]string{"SCHEMA_BUCKET": "b", "HEALTH_PORT": "8080"}, ""},
+		{"keepalive without timeout", map[string]string{"SCHEMA_BUCKET": "b", "FLIGHT_KEEPALIVE_TIME_MS": "60000", "FLIGHT_KEEPALIVE_TIMEOUT_MS": "0"}, ""},
+		{"no endpoints", map[string]string{"SCHEMA_BUCKET": "b", "FLIGHT_ENDPOINTS": ","}, ""},
+		{"cert without key", map[string]string{"SCHEMA_BUCKET": "b", "FLIGHT_TLS_ENABLED": "true", "FLIGHT_TLS_CERT_FILE": "c.pem"}, ""},
+		{"unknown auth", map[string]string{"SCHEMA_BUCKET": "b", "FLIGHT_AUTH_MODE": "kerberos"}, ""},
+		{"basic auth without username", map[string]string{"SCHEMA_BUCKET": "b", "FLIGHT_AUTH_MODE": "basic"}, ""},
+		{"token auth without token", map[string]string{"SCHEMA_BUCKET": "b", "FLIGHT_AUTH_MODE": "token"}, ""},
+		{"token and token file", map[string]string{"SCHEMA_BUCKET": "b", "FLIGHT_AUTH_MODE": "token", "FLIGHT_AUTH_TOKEN": "t", "FLIGHT_AUTH_TOKEN_FILE": "/f"}, ""},
+		{"unknown fallback", map[string]string{"SCHEMA_BUCKET": "b", "FALLBACK_MODE": "coin-toss"}, ""},
+		{"rules without file", map[string]string{"SCHEMA_BUCKET": "b", "FALLBACK_MODE": "rules"}, ""},
+		{"unknown yaml field", map[string]string{"SCHEMA_BUCKET": "b"}, "schema:\n  buckett: typo\n"},
+		{"invalid yaml", map[string]string{"SCHEMA_BUCKET": "b"}, "schema: [\n"},
+		{"route without model", map[string]string{"SCHEMA_BUCKET": "b"}, "routes:\n  - eventType: a.feature\n"},
+		{"bad route pattern", map[string]string{"SCHEMA_BUCKET": "b"}, "routes:\n  - eventType: \"a.[feature\"\n    model: m\n"},
+		{"duplicate route", map[string]string{"SCHEMA_BUCKET": "b"}, "routes:\n  - {eventType: a, model: m}\n  - {eventType: a, model: n}\n"},
+		{"split without key", map[string]string{"SCHEMA_BUCKET": "b"}, "split:\n  variants:\n    - {name: v1, weight: 1}\n"},
+		{"split without weight", map[string]string{"SCHEMA_BUCKET": "b"}, "split:\n  field: app_id\n  variants:\n    - {name: v1, weight: 0}\n"},
+		{"split with routes", map[string]string{"SCHEMA_BUCKET": "b"}, "routes:\n  - {eventType: a, model: m}\nsplit:\n  field: app_id\n  variants:\n    - {name: v1, weight: 1}\n"},
+		{"challenger without endpoint", map[string]string{"SCHEMA_BUCKET": "b"}, "shadow:\n  challengers:\n    - name: v2\n"},
+		{"duplicate challenger", map[string]string{"SCHEMA_BUCKET": "b"}, "shadow:\n  challengers:\n    - {name: v2, endpoint: a:1}\n    - {name: v2, endpoint: b:1}\n"},
+		{"no shadow slots", map[string]string{"SCHEMA_BUCKET": "b", "SHADOW_MAX_IN_FLIGHT": "0"}, "shadow:\n  challengers:\n    - {name: v2, endpoint: a:1}\n"},
+		{"bad yaml duration", map[string]string{"SCHEMA_BUCKET": "b"}, "schema:\n  cacheTTL: soon\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.file != "" {
+				tt.env["CONFIG_FILE"] = writeConfigFile(t, tt.file)
+			}
+			cfg, err := load(fakeEnv(tt.env))
+			assert.Error(t, err)
+			assert.Nil(t, cfg)
+		})
+	}
+}
+
+func TestLoad_missing_file(t *testing.T) {
+	_, err := load(fakeEnv(map[string]string{
+		"SCHEMA_BUCKET": "b",
+		"CONFIG_FILE": filepath.Join(t.TempDir(), "missing.yaml"),
+	}))
+	assert.Error(t, err)
+}
+
+func TestConfig_Validate_reports_every_problem(t *testing.T) {
+	cfg := Default()
+	cfg.Port = 0
+	cfg.Retry.MaxAttempts = 0
+	err := cfg.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "schema.bucket")
+		assert.Contains(t, err.Error(), "port")
+		assert.Contains(t, err.Error(), "retry.maxAttempts")
+	}
+}
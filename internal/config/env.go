@@ -0,0 +1,150 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv overrides the configuration with any environment variables that
+// are set. Durations are given in milliseconds.
+func applyEnv(cfg *Config, getenv func(string) string) error {
+	env := envReader{getenv: getenv}
+	env.int("PORT", &cfg.Port)
+	env.int("HEALTH_PORT", &cfg.Health.Port)
+	env.string("HEALTH_PROBE_EVENT_TYPE", &cfg.Health.ProbeEventType)
+
+	env.string("SCHEMA_BUCKET", &cfg.Schema.Bucket)
+	env.string("SCHEMA_PREFIX", &cfg.Schema.Prefix)
+	env.millis("SCHEMA_CACHE_TTL_MS", &cfg.Schema.CacheTTL)
+
+	env.list("FLIGHT_ENDPOINTS", &cfg.Flight.Endpoints)
+	env.string("FLIGHT_BALANCING", &cfg.Flight.Balancing)
+	env.bool("FLIGHT_HEALTH_CHECK_ENABLED", &cfg.Flight.HealthCheck.Enabled)
+	env.string("FLIGHT_HEALTH_CHECK_SERVICE", &cfg.Flight.HealthCheck.Service)
+	env.bool("FLIGHT_TLS_ENABLED", &cfg.Flight.TLS.Enabled)
+	env.string("FLIGHT_TLS_CA_FILE", &cfg.Flight.TLS.CAFile)
+	env.string("FLIGHT_TLS_CERT_FILE", &cfg.Flight.TLS.CertFile)
+	env.string("FLIGHT_TLS_KEY_FILE", &cfg.Flight.TLS.KeyFile)
+	env.string("FLIGHT_TLS_SERVER_NAME", &cfg.Flight.TLS.ServerName)
+	env.string("FLIGHT_AUTH_MODE", &cfg.Flight.Auth.Mode)
+	env.string("FLIGHT_AUTH_USERNAME", &cfg.Flight.Auth.Username)
+	env.string("FLIGHT_AUTH_PASSWORD", &cfg.Flight.Auth.Password)
+	env.millis("FLIGHT_AUTH_TOKEN_TTL_MS", &cfg.Flight.Auth.TokenTTL)
+	env.string("FLIGHT_AUTH_TOKEN", &cfg.Flight.Auth.Token)
+	env.string("FLIGHT_AUTH_TOKEN_FILE", &cfg.Flight.Auth.TokenFile)
+	env.millis("FLIGHT_CONNECT_TIMEOUT_MS", &cfg.Flight.ConnectTimeout)
+	env.millis("FLIGHT_MAX_RECONNECT_BACKOFF_MS", &cfg.Flight.MaxReconnectBackoff)
+	env.millis("FLIGHT_KEEPALIVE_TIME_MS", &cfg.Flight.Keepalive.Time)
+	env.millis("FLIGHT_KEEPALIVE_TIMEOUT_MS", &cfg.Flight.Keepalive.Timeout)
+	env.bool("FLIGHT_KEEPALIVE_PERMIT_WITHOUT_STREAM", &cfg.Flight.Keepalive.PermitWithoutStream)
+	env.millis("FLIGHT_DISCOVERY_INTERVAL_MS", &cfg.Flight.DiscoveryInterval)
+	env.int("FLIGHT_STREAM_MAX_REQUESTS", &cfg.Flight.StreamMaxRequests)
+	env.millis("FLIGHT_STREAM_MAX_AGE_MS", &cfg.Flight.StreamMaxAge)
+
+	env.string("RESPONSE_EVENT_TYPE", &cfg.Response.EventType)
+	env.string("RESPONSE_EVENT_SOURCE", &cfg.Response.Source)
+
+	env.millis("SCHEMA_TIMEOUT_MS", &cfg.Timeouts.LoadSchema)
+	env.millis("SCORING_TIMEOUT_MS", &cfg.Timeouts.Score)
+	env.millis("SCORING_PRIMARY_TIMEOUT_MS", &cfg.Timeouts.PrimaryScore)
+
+	env.int("SCORING_BATCH_SIZE", &cfg.Batching.Size)
+	env.millis("SCORING_BATCH_WAIT_MS", &cfg.Batching.Wait)
+
+	env.int("SCORING_MAX_ATTEMPTS", &cfg.Retry.MaxAttempts)
+	env.millis("SCORING_RETRY_BACKOFF_MS", &cfg.Retry.InitialBackoff)
+	env.millis("SCORING_RETRY_MAX_BACKOFF_MS", &cfg.Retry.MaxBackoff)
+	env.int("BREAKER_FAILURE_THRESHOLD", &cfg.Breaker.FailureThreshold)
+	env.millis("BREAKER_OPEN_MS", &cfg.Breaker.OpenTimeout)
+
+	env.string("FALLBACK_MODE", &cfg.Fallback.Mode)
+	env.json("FALLBACK_SCORES", &cfg.Fallback.Scores)
+	env.string("FALLBACK_RULES_FILE", &cfg.Fallback.RulesFile)
+	env.string("FALLBACK_FLIGHT_ADDRESS", &cfg.Fallback.Endpoint)
+
+	env.string("SHADOW_OUTPUT", &cfg.Shadow.Output)
+	env.millis("SHADOW_TIMEOUT_MS", &cfg.Shadow.Timeout)
+	env.int("SHADOW_MAX_IN_FLIGHT", &cfg.Shadow.MaxInFlight)
+	return env.err
+}
+
+// envReader parses environment variables into the configuration, keeping
+// the first error so that applyEnv reads as a flat list of bindings.
+type envReader struct {
+	getenv func(string) string
+	err error
+}
+
+func (r *envReader) lookup(name string) (string, bool) {
+	if r.err != nil {
+		return "", false
+	}
+	v := r.getenv(name)
+	return v, v != ""
+}
+
+func (r *envReader) fail(name string, err error) {
+	r.err = fmt.Errorf("%s: %w", name, err)
+}
+
+func (r *envReader) string(name string, dst *string) {
+	if v, ok := r.lookup(name); ok {
+		*dst = v
+	}
+}
+
+func (r *envReader) list(name string, dst *[]string) {
+	if v, ok := r.lookup(name); ok {
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*dst = items
+	}
+}
+
+func (r *envReader) int(name string, dst *int) {
+	if v, ok := r.lookup(name); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			r.fail(name, err)
+			return
+		}
+		*dst = n
+	}
+}
+
+func (r *envReader) bool(name string, dst *bool) {
+	if v, ok := r.lookup(name); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			r.fail(name, err)
+			return
+		}
+		*dst = b
+	}
+}
+
+func (r *envReader) millis(name string, dst *time.Duration) {
+	var ms int
+	if _, ok := r.lookup(name); ok {
+		r.int(name, &ms)
+		if r.err == nil {
+			*dst = time.Duration(ms) * time.Millisecond
+		}
+	}
+}
+
+func (r *envReader) json(name string, dst interface{}) {
+	if v, ok := r.lookup(name); ok {
+		err := json.Unmarshal([]byte(v), dst)
+		if err != nil {
+			r.fail(name, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Validate checks that the configuration is complete and consistent,
+// reporting every problem at once.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Port > 0 && c.Port < 65536, "port must be between 1 and 65535, got %d", c.Port)
+	check(c.Health.Port > 0 && c.Health.Port < 65536, "health.port must be between 1 and 65535, got %d", c.Health.Port)
+	check(c.Health.Port != c.Port, "health.port must differ from port")
+
+	check(c.Schema.Bucket != "", "schema.bucket is required")
+	check(c.Schema.CacheTTL > 0, "schema.cacheTTL must be positive")
+
+	check(len(c.Flight.Endpoints) > 0, "flight.endpoints needs at least one endpoint")
+	for i, endpoint := range c.Flight.Endpoints {
+		check(len(c.Flight.Endpoints) == 1 || !strings.Contains(endpoint, "://"),
+			"flight.endpoints[%d] is a target, which can't be combined with other endpoints: %s", i, endpoint)
+	}
+	check(c.Flight.Balancing == BalancingRoundRobin || c.Flight.Balancing == BalancingLeastRequest,
+		"flight.balancing must be one of round_robin or least_request, got %q", c.Flight.Balancing)
+	check(c.Flight.ConnectTimeout > 0, "flight.connectTimeout must be positive")
+	check(c.Flight.MaxReconnectBackoff > 0, "flight.maxReconnectBackoff must be positive")
+	check(c.Flight.Keepalive.Time >= 0, "flight.keepalive.time must not be negative")
+	check(c.Flight.Keepalive.Time == 0 || c.Flight.Keepalive.Timeout > 0, "flight.keepalive.timeout must be positive when keepalive is on")
+	check(c.Flight.DiscoveryInterval >= 0, "flight.discoveryInterval must not be negative")
+	check(c.Flight.StreamMaxRequests >= 0, "flight.streamMaxRequests must not be negative")
+	check(c.Flight.StreamMaxAge >= 0, "flight.streamMaxAge must not be negative")
+	tls := c.Flight.TLS
+	check((tls.CertFile == "") == (tls.KeyFile == ""), "flight.tls.certFile and flight.tls.keyFile must be set together")
+	check(tls.Enabled || (tls.CAFile == "" && tls.CertFile == ""), "flight.tls files are set but flight.tls.enabled is false")
+
+	auth := c.Flight.Auth
+	switch auth.Mode {
+	case AuthNone:
+	case AuthBasic:
+		check(auth.Username != "", "flight.auth.username is required for basic auth")
+		check(auth.TokenTTL >= 0, "flight.auth.tokenTTL must not be negative")
+	case AuthToken:
+		check((auth.Token == "") != (auth.TokenFile == ""), "flight.auth needs exactly one of token or tokenFile for token auth")
+	default:
+		check(false, "flight.auth.mode must be one of basic or token, got %q", auth.Mode)
+	}
+
+	check(c.Response.EventType != "", "response.eventType is required")
+	check(c.Response.Source != "", "response.source is required")
+
+	check(c.Timeouts.LoadSchema >= 0, "timeouts.loadSchema must not be negative")
+	check(c.Timeouts.Score >= 0, "timeouts.score must not be negative")
+	check(c.Timeouts.PrimaryScore >= 0, "timeouts.primaryScore must not be negative")
+
+	check(c.Batching.Size >= 1, "batching.size must be at least 1")
+	check(c.Batching.Size == 1 || c.Batching.Wait > 0, "batching.wait must be positive when batching")
+
+	check(c.Retry.MaxAttempts >= 1, "retry.maxAttempts must be at least 1")
+	check(c.Retry.InitialBackoff >= 0, "retry.initialBackoff must not be negative")
+	check(c.Retry.MaxBackoff >= c.Retry.InitialBackoff, "retry.maxBackoff must be at least retry.initialBackoff")
+	check(c.Retry.Multiplier >= 1, "retry.multiplier must be at least 1")
+
+	check(c.Breaker.FailureThreshold >= 1, "breaker.failureThreshold must be at least 1")
+	check(c.Breaker.OpenTimeout > 0, "breaker.openTimeout must be positive")
+
+	switch c.Fallback.Mode {
+	case FallbackNone:
+	case FallbackStatic:
+		check(len(c.Fallback.Scores) > 0, "fallback.scores is required for static fallback")
+	case FallbackRules:
+		check(c.Fallback.RulesFile != "", "fallback.rulesFile is required for rules fallback")
+	case FallbackFlight:
+		check(c.Fallback.Endpoint != "", "fallback.endpoint is required for flight fallback")
+	default:
+		check(false, "fallback.mode must be one of static, rules or flight, got %q", c.Fallback.Mode)
+	}
+
+	seen := make(map[string]bool)
+	for i, route := range c.Routes {
+		check(route.EventType != "", "routes[%d].eventType is required", i)
+		check(route.Model != "", "routes[%d].model is required", i)
+		_, err := path.Match(route.EventType, "")
+		check(err == nil, "routes[%d].eventType is not a valid pattern: %q", i, route.EventType)
+		check(!seen[route.EventType], "routes[%d].eventType %q is routed twice", i, route.EventType)
+		seen[route.EventType] = true
+	}
+
+	split := c.Split
+	if len(split.Variants) > 0 {
+		check((split.Attribute == "") != (split.Field == ""), "split needs exactly one of split.attribute or split.field")
+		check(len(c.Routes) == 0, "split can't be combined with routes")
+		total := 0
+		variants := make(map[string]bool)
+		for i, variant := range split.Variants {
+			check(variant.Name != "", "split.variants[%d].name is required", i)
+			check(variant.Weight >= 0, "split.variants[%d].weight must not be negative", i)
+			check(!variants[variant.Name], "split.variants[%d].name %q is used twice", i, variant.Name)
+			variants[variant.Name] = true
+			total += variant.Weight
+		}
+		check(total > 0, "split.variants needs a variant with a positive weight")
+	}
+
+	check(c.Shadow.Timeout >= 0, "shadow.timeout must not be negative")
+	check(len(c.Shadow.Challengers) == 0 || c.Shadow.MaxInFlight >= 1, "shadow.maxInFlight must be at least 1")
+	names := make(map[string]bool)
+	for i, challenger := range c.Shadow.Challengers {
+		check(challenger.Name != "", "shadow.challengers[%d].name is required", i)
+		check(challenger.Endpoint != "", "shadow.challengers[%d].endpoint is required", i)
+		check(!names[challenger.Name], "shadow.challengers[%d].name %q is used twice", i, challenger.Name)
+		names[challenger.Name] = true
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+// Package flightauth authenticates the flight client to the model server, or
+// to an authenticating proxy in front of it, by sending a bearer token as gRPC
+// metadata on every call.
+package flightauth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow/flight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	authorizationHeader = "authorization"
+	bearerPrefix = "Bearer "
+	handshakeMethod = "/arrow.flight.protocol.FlightService/Handshake"
+)
+
+// TokenSource supplies the bearer token sent with each call. conn is the
+// connection the call is made on, for sources that fetch tokens from the
+// flight server itself. Invalidate is told about a token the server rejected,
+// so that the next call gets a new one.
+type TokenSource interface {
+	Token(ctx context.Context, conn grpc.ClientConnInterface) (string, error)
+	Invalidate(token string)
+}
+
+// DialOptions authenticates every call made on a connection with tokens from
+// source. A unary call whose token is rejected is retried once with a new
+// token. A rejected stream fails, and the next stream gets a new token.
+func DialOptions(source TokenSource) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(unaryInterceptor(source)),
+		grpc.WithChainStreamInterceptor(streamInterceptor(source)),
+	}
+}
+
+func unaryInterceptor(source TokenSource) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if method == handshakeMethod {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		token, err := source.Token(ctx, cc)
+		if err != nil {
+			return err
+		}
+		err = invoker(withToken(ctx, token), method, req, reply, cc, opts...)
+		if status.Code(err) != codes.Unauthenticated {
+			return err
+		}
+		source.Invalidate(token)
+		retryToken, tokenErr := source.Token(ctx, cc)
+		if tokenErr != nil || retryToken == token {
+			return err
+		}
+		return invoker(withToken(ctx, retryToken), method, req, reply, cc, opts...)
+	}
+}
+
+func streamInterceptor(source TokenSource) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+		streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if method == handshakeMethod {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		token, err := source.Token(ctx, cc)
+		if err != nil {
+			return nil, err
+		}
+		stream, err := streamer(withToken(ctx, token), desc, cc, method, opts...)
+		if err != nil {
+			if status.Code(err) == codes.Unauthenticated {
+				source.Invalidate(token)
+			}
+			return nil, err
+		}
+		return &rejectionWatcher{ClientStream: stream, source: source, token: token}, nil
+	}
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, authorizationHeader, bearerPrefix+token)
+}
+
+// rejectionWatcher invalidates the stream's token if the server rejects it,
+// which is only seen once the stream's first message is read.
+type rejectionWatcher struct {
+	grpc.ClientStream
+	source TokenSource
+	token string
+}
+
+func (w *rejectionWatcher) RecvMsg(m interface{}) error {
+	err := w.ClientStream.RecvMsg(m)
+	if status.Code(err) == codes.Unauthenticated {
+		w.source.Invalidate(w.token)
+	}
+	return err
+}
+
+// StaticToken sends the same token with every call.
+type StaticToken string
+
+func (t StaticToken) Token(ctx context.Context, conn grpc.ClientConnInterface) (string, error) {
+	return string(t), nil
+}
+
+func (t StaticToken) Invalidate(token string) {}
+
+// FileToken reads the token from a file, rereading it whenever the file
+// changes, for tokens rotated on disk by something else such as a projected
+// service account token.
+type FileToken struct {
+	path string
+	mu sync.Mutex
+	token string
+	modTime time.Time
+	size int64
+}
+
+// NewFileToken fails if the file can't be read or is empty.
+func NewFileToken(path string) (*FileToken, error) {
+	f := &FileToken{path: path}
+	_, err := f.Token(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileToken) Token(ctx context.Context, conn grpc.ClientConnInterface) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", fmt.Errorf("error reading token file: %w", err)
+	}
+	if f.token != "" && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.token, nil
+	}
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return "", fmt.Errorf("error reading token file: %w", err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token file is empty: %s", f.path)
+	}
+	f.token, f.modTime, f.size = token, info.ModTime(), info.Size()
+	return token, nil
+}
+
+// Invalidate rereads the file on the next call, in case it was rotated within
+// the file system's timestamp resolution.
+func (f *FileToken) Invalidate(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.token == token {
+		f.token = ""
+	}
+}
+
+// handshakeTimeout bounds a handshake. Every call waiting for a token shares
+// it, so it can't be bounded by any one of their deadlines.
+const handshakeTimeout = 30 * time.Second
+
+// BasicHandshake exchanges a username and password for a bearer token using
+// the flight Handshake call, sending them as HTTP basic credentials and
+// reading the token from the authorization header of the response. The token
+// is reused until TTL has passed, if TTL is set, or until it is rejected.
+type BasicHandshake struct {
+	username string
+	password string
+	ttl time.Duration
+	mu sync.Mutex
+	token string
+	expires time.Time
+	pending *handshakeCall
+}
+
+// handshakeCall is a handshake in progress. done is closed once token or err is set.
+type handshakeCall struct {
+	done chan struct{}
+	token string
+	err error
+}
+
+func NewBasicHandshake(username, password string, ttl time.Duration) *BasicHandshake {
+	return &BasicHandshake{username: username, password: password, ttl: ttl}
+}
+
+// Token returns the current token, or waits for a new one until ctx is done.
+// Concurrent calls share one handshake.
+func (b *BasicHandshake) Token(ctx context.Context, conn grpc.ClientConnInterface) (string, error) {
+	b.mu.Lock()
+	if b.token != "" && (b.ttl <= 0 || time.Now().Before(b.expires)) {
+		token := b.token
+		b.mu.Unlock()
+		return token, nil
+	}
+	call := b.pending
+	if call == nil {
+		call = &handshakeCall{done: make(chan struct{})}
+		b.pending = call
+		go b.run(call, flight.NewFlightServiceClient(conn))
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return "", fmt.Errorf("flight handshake failed: %w", ctx.Err())
+	}
+	if call.err != nil {
+		return "", fmt.Errorf("flight handshake failed: %w", call.err)
+	}
+	return call.token, nil
+}
+
+func (b *BasicHandshake) Invalidate(token string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.token == token {
+		b.token = ""
+	}
+}
+
+func (b *BasicHandshake) run(call *handshakeCall, client flight.FlightServiceClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	call.token, call.err = b.handshake(ctx, client)
+	b.mu.Lock()
+	if call.err == nil {
+		b.token = call.token
+		b.expires = time.Now().Add(b.ttl)
+	}
+	b.pending = nil
+	b.mu.Unlock()
+	close(call.done)
+}
+
+func (b *BasicHandshake) handshake(ctx context.Context, client flight.FlightServiceClient) (string, error) {
+	credentials := base64.StdEncoding.EncodeToString([]byte(b.username + ":" + b.password))
+	ctx = metadata.AppendToOutgoingContext(ctx, authorizationHeader, "Basic "+credentials)
+	stream, err := client.Handshake(ctx)
+	if err != nil {
+		return "", err
+	}
+	err = stream.CloseSend()
+	if err != nil {
+		return "", err
+	}
+	header, err := stream.Header()
+	if err != nil {
+		return "", err
+	}
+	for {
+		_, err = stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	md := metadata.Join(header, stream.Trailer())
+	for _, value := range md.Get(authorizationHeader) {
+		if strings.HasPrefix(value, bearerPrefix) && len(value) > len(bearerPrefix) {
+			return strings.TrimPrefix(value, bearerPrefix), nil
+		}
+	}
+	return "", errors.New("no bearer token in the handshake response")
+}
@@ -0,0 +1,255 @@
+package flightauth
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow/flight"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// authServer issues tokens for basic credentials over Handshake and only
+// answers GetSchema and ListFlights for valid tokens.
+type authServer struct {
+	mu sync.Mutex
+	handshakes int
+	valid map[string]bool
+}
+
+func (a *authServer) issue() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.handshakes++
+	token := fmt.Sprintf("token-%d", a.handshakes)
+	a.valid[token] = true
+	return token
+}
+
+func (a *authServer) allow(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.valid[token] = true
+}
+
+// revoke makes every issued token invalid.
+func (a *authServer) revoke() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.valid = make(map[string]bool)
+}
+
+func (a *authServer) handshakeCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.handshakes
+}
+
+func (a *authServer) check(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, value := range md.Get("authorization") {
+		if a.valid[value[len("Bearer "):]] {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid token")
+}
+
+func startAuthServer(t *testing.T) (*authServer, string) {
+	auth := &authServer{valid: make(map[string]bool)}
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("decisioner:secret"))
+	server := flight.NewServerWithMiddleware(nil, nil)
+	server.RegisterFlightService(&flight.FlightServiceService{
+		Handshake: func(stream flight.FlightService_HandshakeServer) error {
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			if values := md.Get("authorization"); len(values) == 0 || values[0] != basic {
+				return status.Error(codes.Unauthenticated, "invalid credentials")
+			}
+			return stream.SetHeader(metadata.Pairs("authorization", "Bearer "+auth.issue()))
+		},
+		GetSchema: func(ctx context.Context, d *flight.FlightDescriptor) (*flight.SchemaResult, error) {
+			if err := auth.check(ctx); err != nil {
+				return nil, err
+			}
+			return &flight.SchemaResult{}, nil
+		},
+		ListFlights: func(c *flight.Criteria, stream flight.FlightService_ListFlightsServer) error {
+			if err := auth.check(stream.Context()); err != nil {
+				return err
+			}
+			return stream.Send(&flight.FlightInfo{})
+		},
+	})
+	if err := server.Init("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	t.Cleanup(server.Shutdown)
+	return auth, server.Addr().String()
+}
+
+func newAuthClient(t *testing.T, addr string, source TokenSource) flight.FlightServiceClient {
+	opts := append([]grpc.DialOption{grpc.WithInsecure()}, DialOptions(source)...)
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return flight.NewFlightServiceClient(conn)
+}
+
+func getSchema(client flight.FlightServiceClient) error {
+	_, err := client.GetSchema(context.Background(), &flight.FlightDescriptor{Type: flight.FlightDescriptor_PATH})
+	return err
+}
+
+func listFlights(client flight.FlightServiceClient) error {
+	stream, err := client.ListFlights(context.Background(), &flight.Criteria{})
+	if err != nil {
+		return err
+	}
+	_, err = stream.Recv()
+	return err
+}
+
+func TestStaticToken(t *testing.T) {
+	auth, addr := startAuthServer(t)
+	auth.allow("static")
+
+	assert.Nil(t, getSchema(newAuthClient(t, addr, StaticToken("static"))))
+	assert.Nil(t, listFlights(newAuthClient(t, addr, StaticToken("static"))))
+	err := getSchema(newAuthClient(t, addr, StaticToken("forged")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestFileToken(t *testing.T) {
+	auth, addr := startAuthServer(t)
+	auth.allow("first")
+	auth.allow("second")
+	path := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(path, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewFileToken(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newAuthClient(t, addr, source)
+	assert.Nil(t, getSchema(client))
+
+	// rotate the token and revoke the old one.
+	if err := ioutil.WriteFile(path, []byte("second\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	auth.revoke()
+	auth.allow("second")
+	assert.Nil(t, getSchema(client), "the rotated token should be used")
+}
+
+func TestNewFileToken_errors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	if err := ioutil.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewFileToken(empty)
+	assert.Error(t, err)
+	_, err = NewFileToken(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestBasicHandshake(t *testing.T) {
+	auth, addr := startAuthServer(t)
+	client := newAuthClient(t, addr, NewBasicHandshake("decisioner", "secret", 0))
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, getSchema(client))
+	}
+	assert.Equal(t, 1, auth.handshakeCount(), "the token should be reused")
+}
+
+func TestBasicHandshake_refreshes_rejected_token(t *testing.T) {
+	auth, addr := startAuthServer(t)
+	client := newAuthClient(t, addr, NewBasicHandshake("decisioner", "secret", 0))
+	assert.Nil(t, getSchema(client))
+
+	auth.revoke()
+	assert.Nil(t, getSchema(client), "a rejected unary call should be retried with a new token")
+	assert.Equal(t, 2, auth.handshakeCount())
+
+	auth.revoke()
+	assert.Equal(t, codes.Unauthenticated, status.Code(listFlights(client)), "a rejected stream fails")
+	assert.Nil(t, listFlights(client), "the next stream should use a new token")
+	assert.Equal(t, 3, auth.handshakeCount())
+}
+
+func TestBasicHandshake_refreshes_expired_token(t *testing.T) {
+	auth, addr := startAuthServer(t)
+	client := newAuthClient(t, addr, NewBasicHandshake("decisioner", "secret", 20*time.Millisecond))
+	assert.Nil(t, getSchema(client))
+	time.Sleep(30 * time.Millisecond)
+	assert.Nil(t, getSchema(client))
+	assert.Equal(t, 2, auth.handshakeCount())
+}
+
+func TestBasicHandshake_bad_credentials(t *testing.T) {
+	auth, addr := startAuthServer(t)
+	client := newAuthClient(t, addr, NewBasicHandshake("decisioner", "guess", 0))
+	err := getSchema(client)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "flight handshake failed")
+	}
+	assert.Equal(t, 0, auth.handshakeCount())
+}
+
+func TestBasicHandshake_stalled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server := flight.NewServerWithMiddleware(nil, nil)
+	server.RegisterFlightService(&flight.FlightServiceService{
+		Handshake: func(stream flight.FlightService_HandshakeServer) error {
+			select {
+			case <-release:
+			case <-stream.Context().Done():
+			}
+			return status.Error(codes.Unavailable, "stalled")
+		},
+	})
+	if err := server.Init("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	t.Cleanup(server.Shutdown)
+	conn, err := grpc.Dial(server.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	source := NewBasicHandshake("decisioner", "secret", 0)
+
+	// a call with no deadline starts the handshake, which mustn't hold up
+	// later calls beyond their own deadlines.
+	go func() { _, _ = source.Token(context.Background(), conn) }()
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = source.Token(ctx, conn)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
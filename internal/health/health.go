@@ -0,0 +1,121 @@
+// Package health reports whether the decisioner is alive and ready to score
+// events, as JSON for liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/grpc/connectivity"
+	"log"
+	"net/http"
+	"time"
+)
+
+// probeTimeout bounds each check when the request sets no deadline.
+const probeTimeout = 5 * time.Second
+
+// Check is one dependency of readiness. Probe returns a short description of
+// the dependency's state, and an error if it isn't usable. An Optional check
+// is reported but doesn't make the service unready, for dependencies that
+// events can be handled without.
+type Check struct {
+	Name string
+	Probe func(ctx context.Context) (string, error)
+	Optional bool
+}
+
+// Report is the body of a readiness response.
+type Report struct {
+	Status string `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type CheckResult struct {
+	Status string `json:"status"`
+	State string `json:"state,omitempty"`
+	Error string `json:"error,omitempty"`
+	Optional bool `json:"optional,omitempty"`
+}
+
+const (
+	StatusUp = "up"
+	StatusDown = "down"
+)
+
+// Run probes every check, reporting up only if all of the required ones are.
+func Run(ctx context.Context, checks []Check) Report {
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(checks))}
+	for _, check := range checks {
+		state, err := check.Probe(ctx)
+		result := CheckResult{Status: StatusUp, State: state, Optional: check.Optional}
+		if err != nil {
+			result.Status = StatusDown
+			result.Error = err.Error()
+			if !check.Optional {
+				report.Status = StatusDown
+			}
+		}
+		report.Checks[check.Name] = result
+	}
+	return report
+}
+
+// Handler serves the report for checks, with status 503 unless every required
+// check is up.
+func Handler(checks []Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+		defer cancel()
+		report := Run(ctx, checks)
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status != StatusUp {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		err := json.NewEncoder(w).Encode(report)
+		if err != nil {
+			log.Printf("error writing health report: %s", err)
+		}
+	})
+}
+
+// Conn is the part of *grpc.ClientConn needed to check it.
+type Conn interface {
+	GetState() connectivity.State
+	Connect()
+}
+
+// ConnCheck reports a gRPC connection's connectivity state. Only a READY
+// connection is up. An IDLE connection is asked to connect, so that it is
+// ready by the next probe.
+func ConnCheck(name string, conn Conn) Check {
+	return Check{Name: name, Probe: func(ctx context.Context) (string, error) {
+		state := conn.GetState()
+		if state == connectivity.Idle {
+			conn.Connect()
+		}
+		if state != connectivity.Ready {
+			return state.String(), fmt.Errorf("connection is %s", state)
+		}
+		return state.String(), nil
+	}}
+}
+
+// CodecLoader is the part of avroutil.AvroCodecLoader needed to check it.
+type CodecLoader interface {
+	LoadCodec(context.Context, string) (*goavro.Codec, error)
+}
+
+// CodecCheck reports whether the schema for eventType can be loaded. Loaders
+// cache schemas, so the schema store itself is only reached once the cached
+// schema expires.
+func CodecCheck(name string, loader CodecLoader, eventType string) Check {
+	return Check{Name: name, Probe: func(ctx context.Context) (string, error) {
+		_, err := loader.LoadCodec(ctx, eventType)
+		if err != nil {
+			return "", fmt.Errorf("error loading schema: event: %s, reason: %w", eventType, err)
+		}
+		return "loaded " + eventType, nil
+	}}
+}
@@ -0,0 +1,122 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/connectivity"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeConn struct {
+	state connectivity.State
+	connects int
+}
+
+func (c *fakeConn) GetState() connectivity.State {
+	return c.state
+}
+
+func (c *fakeConn) Connect() {
+	c.connects++
+}
+
+type fakeLoader struct {
+	err error
+}
+
+func (l fakeLoader) LoadCodec(ctx context.Context, eventType string) (*goavro.Codec, error) {
+	if l.err != nil {
+		return nil, l.err
+	}
+	return goavro.NewCodec(`"string"`)
+}
+
+func serve(t *testing.T, checks []Check) (int, Report) {
+	recorder := httptest.NewRecorder()
+	Handler(checks).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	var report Report
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code, report
+}
+
+func TestHandler_up(t *testing.T) {
+	code, report := serve(t, []Check{
+		ConnCheck("flight:model-a:9998", &fakeConn{state: connectivity.Ready}),
+		ConnCheck("flight:model-b:9998", &fakeConn{state: connectivity.Ready}),
+	})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusUp, report.Status)
+	assert.Equal(t, CheckResult{Status: StatusUp, State: "READY"}, report.Checks["flight:model-a:9998"])
+}
+
+func TestHandler_down(t *testing.T) {
+	code, report := serve(t, []Check{
+		ConnCheck("flight:model-a:9998", &fakeConn{state: connectivity.Ready}),
+		ConnCheck("flight:model-b:9998", &fakeConn{state: connectivity.TransientFailure}),
+	})
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, StatusUp, report.Checks["flight:model-a:9998"].Status)
+	assert.Equal(t, CheckResult{Status: StatusDown, State: "TRANSIENT_FAILURE", Error: "connection is TRANSIENT_FAILURE"},
+		report.Checks["flight:model-b:9998"])
+}
+
+func TestHandler_optional_down(t *testing.T) {
+	optional := ConnCheck("flight:model-b:9998", &fakeConn{state: connectivity.TransientFailure})
+	optional.Optional = true
+	code, report := serve(t, []Check{
+		ConnCheck("flight:model-a:9998", &fakeConn{state: connectivity.Ready}),
+		optional,
+	})
+	assert.Equal(t, http.StatusOK, code, "an optional check shouldn't make the service unready")
+	assert.Equal(t, StatusUp, report.Status)
+	assert.Equal(t, CheckResult{Status: StatusDown, State: "TRANSIENT_FAILURE", Error: "connection is TRANSIENT_FAILURE", Optional: true},
+		report.Checks["flight:model-b:9998"], "an optional check should still be reported")
+}
+
+func TestHandler_probe_error(t *testing.T) {
+	code, report := serve(t, []Check{{Name: "broken", Probe: func(ctx context.Context) (string, error) {
+		return "", errors.New("unreachable")
+	}}})
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unreachable", report.Checks["broken"].Error)
+}
+
+func TestConnCheck_connects_idle(t *testing.T) {
+	conn := &fakeConn{state: connectivity.Idle}
+	_, err := ConnCheck("flight", conn).Probe(context.Background())
+	assert.Error(t, err, "an idle connection isn't ready yet")
+	assert.Equal(t, 1, conn.connects)
+}
+
+func TestHandler_no_checks(t *testing.T) {
+	code, report := serve(t, nil)
+	assert.Equal(t, http.StatusOK, code, "liveness has nothing to check")
+	assert.Equal(t, Report{Status: StatusUp, Checks: map[string]CheckResult{}}, report)
+}
+
+func TestCodecCheck(t *testing.T) {
+	code, report := serve(t, []Check{
+		CodecCheck("schema:decision", fakeLoader{}, "decision"),
+		ConnCheck("flight:model-a:9998", &fakeConn{state: connectivity.Ready}),
+	})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, CheckResult{Status: StatusUp, State: "loaded decision"}, report.Checks["schema:decision"])
+
+	code, report = serve(t, []Check{
+		CodecCheck("schema:decision", fakeLoader{err: errors.New("access denied")}, "decision"),
+		ConnCheck("flight:model-a:9998", &fakeConn{state: connectivity.Ready}),
+	})
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, CheckResult{Status: StatusDown, Error: "error loading schema: event: decision, reason: access denied"},
+		report.Checks["schema:decision"])
+	assert.Equal(t, StatusUp, report.Checks["flight:model-a:9998"].Status)
+}
@@ -0,0 +1,64 @@
+// Package leastrequest registers a gRPC load balancing policy that sends each
+// call to the ready replica with the fewest calls in flight, so that a slow
+// replica is given less work than round robin would give it.
+package leastrequest
+
+import (
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"sync/atomic"
+)
+
+// Name is the policy's name in a gRPC service config.
+const Name = "least_request"
+
+func init() {
+	balancer.Register(base.NewBalancerBuilder(Name, pickerBuilder{}, base.Config{HealthCheck: true}))
+}
+
+type pickerBuilder struct{}
+
+// Build is called whenever the set of ready replicas changes. Counts of calls
+// in flight start again from zero, which only skews the first few picks.
+func (pickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	p := &picker{}
+	for sc := range info.ReadySCs {
+		p.replicas = append(p.replicas, &replica{conn: sc})
+	}
+	return p
+}
+
+type replica struct {
+	conn balancer.SubConn
+	outstanding int64
+}
+
+type picker struct {
+	replicas []*replica
+	next uint32
+}
+
+// Pick scans from a rotating start, so that ties are spread across replicas.
+func (p *picker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	n := uint32(len(p.replicas))
+	start := atomic.AddUint32(&p.next, 1) % n
+	var best *replica
+	var fewest int64
+	for i := uint32(0); i < n; i++ {
+		r := p.replicas[(start+i)%n]
+		outstanding := atomic.LoadInt64(&r.outstanding)
+		if best == nil || outstanding < fewest {
+			best, fewest = r, outstanding
+		}
+	}
+	atomic.AddInt64(&best.outstanding, 1)
+	return balancer.PickResult{
+		SubConn: best.conn,
+		Done: func(balancer.DoneInfo) {
+			atomic.AddInt64(&best.outstanding, -1)
+		},
+	}, nil
+}
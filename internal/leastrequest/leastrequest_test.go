@@ -0,0 +1,78 @@
+package leastrequest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/resolver"
+	"math"
+	"testing"
+)
+
+type fakeSubConn struct {
+	name string
+}
+
+func (c *fakeSubConn) UpdateAddresses([]resolver.Address) {}
+func (c *fakeSubConn) Connect() {}
+
+func buildPicker(conns ...balancer.SubConn) balancer.Picker {
+	ready := make(map[balancer.SubConn]base.SubConnInfo)
+	for _, conn := range conns {
+		ready[conn] = base.SubConnInfo{}
+	}
+	return pickerBuilder{}.Build(base.PickerBuildInfo{ReadySCs: ready})
+}
+
+func TestPicker_prefers_fewest_outstanding(t *testing.T) {
+	slow, fast := &fakeSubConn{"slow"}, &fakeSubConn{"fast"}
+	picker := buildPicker(slow, fast)
+
+	// hold a call open on whichever replica is picked first.
+	held, err := picker.Pick(balancer.PickInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		result, err := picker.Pick(balancer.PickInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NotSame(t, held.SubConn, result.SubConn, "the busy replica should be avoided")
+		result.Done(balancer.DoneInfo{})
+	}
+	held.Done(balancer.DoneInfo{})
+}
+
+func TestPicker_spreads_ties(t *testing.T) {
+	a, b := &fakeSubConn{"a"}, &fakeSubConn{"b"}
+	picker := buildPicker(a, b)
+	counts := make(map[balancer.SubConn]int)
+	for i := 0; i < 10; i++ {
+		result, err := picker.Pick(balancer.PickInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[result.SubConn]++
+		result.Done(balancer.DoneInfo{})
+	}
+	assert.Equal(t, 5, counts[a])
+	assert.Equal(t, 5, counts[b])
+}
+
+func TestPicker_wraps_around(t *testing.T) {
+	a, b, c := &fakeSubConn{"a"}, &fakeSubConn{"b"}, &fakeSubConn{"c"}
+	p := buildPicker(a, b, c).(*picker)
+	p.next = math.MaxUint32 - 1
+	for i := 0; i < 4; i++ {
+		result, err := p.Pick(balancer.PickInfo{})
+		if assert.Nil(t, err) {
+			result.Done(balancer.DoneInfo{})
+		}
+	}
+}
+
+func TestPicker_no_replicas(t *testing.T) {
+	_, err := buildPicker().Pick(balancer.PickInfo{})
+	assert.Equal(t, balancer.ErrNoSubConnAvailable, err)
+}
@@ -0,0 +1,145 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/ehenry2/avro-flight-decisioner/internal/arrowconv"
+	"sync"
+	"time"
+)
+
+// BatchingScorer collects concurrent requests that share a schema into a
+// single multi-row batch. A batch is sent once it holds maxRows rows or its
+// first row has waited maxWait, whichever comes first, and each caller gets
+// back the scores for its own row. A row whose features can't be converted
+// fails only its own caller.
+//
+// Batches are keyed by schema pointer rather than fingerprint, since the
+// fingerprint ignores the field metadata that distinguishes avro unions and
+// enums. The codec loader hands out one schema per avro schema, so requests
+// for the same event type still land in the same batch.
+type BatchingScorer struct {
+	scorer BatchScorer
+	maxRows int
+	maxWait time.Duration
+
+	mu sync.Mutex
+	pending map[*arrow.Schema]*pendingBatch
+}
+
+type pendingBatch struct {
+	schema *arrow.Schema
+	features []map[string]interface{}
+	results []chan batchResult
+	timer *time.Timer
+	// deadline is the latest deadline of the requests in the batch, bounded
+	// is false if any of them has none.
+	deadline time.Time
+	bounded bool
+}
+
+type batchResult struct {
+	scores map[string]interface{}
+	err error
+}
+
+func NewBatchingScorer(scorer BatchScorer, maxRows int, maxWait time.Duration) *BatchingScorer {
+	return &BatchingScorer{
+		scorer: scorer,
+		maxRows: maxRows,
+		maxWait: maxWait,
+		pending: make(map[*arrow.Schema]*pendingBatch),
+	}
+}
+
+// ScoreModel adds the features to the pending batch for their schema and
+// blocks until that batch has been scored or ctx is done. One caller giving up
+// doesn't cancel the batch for the others, so the batch runs until the latest
+// of their deadlines.
+func (s *BatchingScorer) ScoreModel(ctx context.Context, schema *arrow.Schema, features map[string]interface{}) (map[string]interface{}, error) {
+	result := make(chan batchResult, 1)
+	deadline, bounded := ctx.Deadline()
+
+	s.mu.Lock()
+	batch, ok := s.pending[schema]
+	if !ok {
+		batch = &pendingBatch{schema: schema, deadline: deadline, bounded: bounded}
+		s.pending[schema] = batch
+		batch.timer = time.AfterFunc(s.maxWait, func() {
+			s.flush(batch)
+		})
+	}
+	if !bounded {
+		batch.bounded = false
+	} else if deadline.After(batch.deadline) {
+		batch.deadline = deadline
+	}
+	batch.features = append(batch.features, features)
+	batch.results = append(batch.results, result)
+	full := len(batch.features) >= s.maxRows
+	if full {
+		batch.timer.Stop()
+		delete(s.pending, schema)
+	}
+	s.mu.Unlock()
+
+	if full {
+		go s.score(batch)
+	}
+	select {
+	case r := <-result:
+		return r.scores, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flush sends a batch whose wait has expired, unless it already filled up.
+func (s *BatchingScorer) flush(batch *pendingBatch) {
+	s.mu.Lock()
+	if s.pending[batch.schema] != batch {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.pending, batch.schema)
+	s.mu.Unlock()
+	s.score(batch)
+}
+
+func (s *BatchingScorer) score(batch *pendingBatch) {
+	ctx := context.Background()
+	if batch.bounded {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, batch.deadline)
+		defer cancel()
+	}
+	features, results := batch.features, batch.results
+	for len(features) > 0 {
+		scores, err := s.scorer.ScoreBatch(ctx, batch.schema, features)
+		var rowErr *arrowconv.RowError
+		if errors.As(err, &rowErr) && rowErr.Row >= 0 && rowErr.Row < len(features) {
+			// only the caller whose features didn't convert fails, the rest
+			// of the batch is scored without them.
+			row := rowErr.Row
+			results[row] <- batchResult{err: &featureError{
+				err: &arrowconv.RowError{Row: 0, Field: rowErr.Field, Err: rowErr.Err},
+			}}
+			features = append(features[:row:row], features[row+1:]...)
+			results = append(results[:row:row], results[row+1:]...)
+			continue
+		}
+		if err == nil && len(scores) != len(results) {
+			err = fmt.Errorf("model returned %d rows of scores for %d rows of features", len(scores), len(results))
+		}
+		for i, result := range results {
+			if err != nil {
+				result <- batchResult{err: err}
+				continue
+			}
+			result <- batchResult{scores: scores[i]}
+		}
+		return
+	}
+}
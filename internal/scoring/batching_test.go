@@ -0,0 +1,189 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/ehenry2/avro-flight-decisioner/internal/arrowconv"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingScorer echoes each row's id back as its score and records the
+// size of every batch it was asked to score.
+type recordingScorer struct {
+	mu sync.Mutex
+	batches []int
+	err error
+}
+
+func (s *recordingScorer) ScoreBatch(ctx context.Context, schema *arrow.Schema, features []map[string]interface{}) ([]map[string]interface{}, error) {
+	s.mu.Lock()
+	s.batches = append(s.batches, len(features))
+	s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	scores := make([]map[string]interface{}, len(features))
+	for i, row := range features {
+		scores[i] = map[string]interface{}{"score": row["id"]}
+	}
+	return scores, nil
+}
+
+func (s *recordingScorer) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.batches...)
+}
+
+// scoreConcurrently scores n rows at once and returns the scores by row.
+func scoreConcurrently(scorer ModelScorer, schema *arrow.Schema, n int) ([]map[string]interface{}, []error) {
+	scores := make([]map[string]interface{}, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			scores[i], errs[i] = scorer.ScoreModel(context.Background(), schema, map[string]interface{}{"id": fmt.Sprint(i)})
+		}(i)
+	}
+	wg.Wait()
+	return scores, errs
+}
+
+func TestBatchingScorer_flushes_when_full(t *testing.T) {
+	inner := &recordingScorer{}
+	scorer := NewBatchingScorer(inner, 4, time.Hour)
+	scores, errs := scoreConcurrently(scorer, testFeatureSchema, 8)
+	for i := range scores {
+		assert.Nil(t, errs[i])
+		assert.Equal(t, fmt.Sprint(i), scores[i]["score"])
+	}
+	assert.Equal(t, []int{4, 4}, inner.batchSizes())
+}
+
+func TestBatchingScorer_flushes_after_wait(t *testing.T) {
+	inner := &recordingScorer{}
+	scorer := NewBatchingScorer(inner, 100, 20*time.Millisecond)
+	start := time.Now()
+	scores, errs := scoreConcurrently(scorer, testFeatureSchema, 3)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	for i := range scores {
+		assert.Nil(t, errs[i])
+		assert.Equal(t, fmt.Sprint(i), scores[i]["score"])
+	}
+	assert.Equal(t, []int{3}, inner.batchSizes())
+}
+
+func TestBatchingScorer_separates_schemas(t *testing.T) {
+	inner := &recordingScorer{}
+	scorer := NewBatchingScorer(inner, 2, time.Hour)
+	var wg sync.WaitGroup
+	for _, schema := range []*arrow.Schema{testFeatureSchema, testScoreSchema} {
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(schema *arrow.Schema) {
+				defer wg.Done()
+				_, err := scorer.ScoreModel(context.Background(), schema, map[string]interface{}{"id": "a"})
+				assert.Nil(t, err)
+			}(schema)
+		}
+	}
+	wg.Wait()
+	assert.Equal(t, []int{2, 2}, inner.batchSizes())
+}
+
+func TestBatchingScorer_error(t *testing.T) {
+	inner := &recordingScorer{err: errors.New("model server down")}
+	scorer := NewBatchingScorer(inner, 2, time.Hour)
+	_, errs := scoreConcurrently(scorer, testFeatureSchema, 2)
+	for _, err := range errs {
+		assert.ErrorIs(t, err, inner.err)
+	}
+}
+
+// shortScorer returns one fewer row of scores than it was given, without an error.
+type shortScorer struct{}
+
+func (shortScorer) ScoreBatch(ctx context.Context, schema *arrow.Schema, features []map[string]interface{}) ([]map[string]interface{}, error) {
+	return make([]map[string]interface{}, len(features)-1), nil
+}
+
+func TestBatchingScorer_row_mismatch(t *testing.T) {
+	scorer := NewBatchingScorer(shortScorer{}, 2, time.Hour)
+	scores, errs := scoreConcurrently(scorer, testFeatureSchema, 2)
+	for i := range scores {
+		assert.Nil(t, scores[i])
+		assert.EqualError(t, errs[i], "model returned 1 rows of scores for 2 rows of features")
+	}
+}
+
+// blockingScorer holds every batch until its context is done.
+type blockingScorer struct {
+	deadlines chan time.Time
+}
+
+func (s *blockingScorer) ScoreBatch(ctx context.Context, schema *arrow.Schema, features []map[string]interface{}) ([]map[string]interface{}, error) {
+	deadline, _ := ctx.Deadline()
+	s.deadlines <- deadline
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestBatchingScorer_caller_deadline(t *testing.T) {
+	inner := &blockingScorer{deadlines: make(chan time.Time, 1)}
+	scorer := NewBatchingScorer(inner, 2, time.Hour)
+	short, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	long, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	longDeadline, _ := long.Deadline()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, ctx := range []context.Context{short, long} {
+		wg.Add(1)
+		go func(i int, ctx context.Context) {
+			defer wg.Done()
+			_, errs[i] = scorer.ScoreModel(ctx, testFeatureSchema, map[string]interface{}{"id": "a"})
+		}(i, ctx)
+	}
+	wg.Wait()
+	assert.Equal(t, longDeadline, <-inner.deadlines, "the batch should run until the latest deadline")
+	assert.ErrorIs(t, errs[0], context.DeadlineExceeded)
+	assert.ErrorIs(t, errs[1], context.DeadlineExceeded)
+}
+
+func TestBatchingScorer_row_conversion_error(t *testing.T) {
+	scorer := NewBatchingScorer(newTestScorer(t, doubleAmount), 2, time.Hour)
+	features := []map[string]interface{}{
+		{"amount": "not a number", "id": "a"},
+		{"amount": 2.0, "id": "b"},
+	}
+	scores := make([]map[string]interface{}, len(features))
+	errs := make([]error, len(features))
+	var wg sync.WaitGroup
+	for i := range features {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			scores[i], errs[i] = scorer.ScoreModel(context.Background(), testFeatureSchema, features[i])
+		}(i)
+	}
+	wg.Wait()
+
+	// only the caller with the bad row fails, and its error names its own row.
+	assert.ErrorIs(t, errs[0], ErrConversion)
+	var rowErr *arrowconv.RowError
+	if assert.True(t, errors.As(errs[0], &rowErr), "the row error should be reachable: %v", errs[0]) {
+		assert.Equal(t, 0, rowErr.Row)
+		assert.Equal(t, "amount", rowErr.Field)
+	}
+	assert.Nil(t, errs[1])
+	assert.Equal(t, map[string]interface{}{"score": 4.0}, scores[1])
+}
@@ -0,0 +1,124 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"github.com/apache/arrow/go/v7/arrow"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the model server while the
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState int
+
+const (
+	// BreakerClosed passes every request through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails every request fast.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial request through to probe the server.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig controls when the circuit breaker trips and recovers.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before a trial request.
+	OpenTimeout time.Duration
+}
+
+// CircuitBreakerScorer stops calling the model server after repeated
+// failures, so events fail fast instead of each waiting out its deadline.
+// Conversion errors and cancelled requests say nothing about the server's
+// health and are not counted.
+type CircuitBreakerScorer struct {
+	scorer ModelScorer
+	cfg BreakerConfig
+
+	mu sync.Mutex
+	state BreakerState
+	failures int
+	openedAt time.Time
+}
+
+func NewCircuitBreakerScorer(scorer ModelScorer, cfg BreakerConfig) *CircuitBreakerScorer {
+	return &CircuitBreakerScorer{scorer: scorer, cfg: cfg}
+}
+
+// State reports the current state of the breaker, for health checks.
+func (b *CircuitBreakerScorer) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cfg.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+func (b *CircuitBreakerScorer) ScoreModel(ctx context.Context, schema *arrow.Schema, features map[string]interface{}) (map[string]interface{}, error) {
+	if !b.allow() {
+		return nil, ErrCircuitOpen
+	}
+	scores, err := b.scorer.ScoreModel(ctx, schema, features)
+	b.record(err)
+	return scores, err
+}
+
+// allow reports whether a request may go through, moving an open breaker to
+// half open once its timeout has passed.
+func (b *CircuitBreakerScorer) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cfg.OpenTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		return true
+	default:
+		// a trial request is already in flight.
+		return false
+	}
+}
+
+func (b *CircuitBreakerScorer) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+	if errors.Is(err, ErrConversion) || errors.Is(err, context.Canceled) {
+		// the server wasn't at fault, but a trial must still end.
+		if b.state == BreakerHalfOpen {
+			b.state = BreakerOpen
+		}
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.cfg.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
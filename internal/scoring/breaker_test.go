@@ -0,0 +1,71 @@
+package scoring
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+var testBreakerConfig = BreakerConfig{
+	FailureThreshold: 2,
+	OpenTimeout: 20 * time.Millisecond,
+}
+
+func TestCircuitBreakerScorer_opens_after_failures(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	inner := &scriptedScorer{errs: []error{unavailable, unavailable}}
+	breaker := NewCircuitBreakerScorer(inner, testBreakerConfig)
+
+	for i := 0; i < 2; i++ {
+		_, err := breaker.ScoreModel(context.Background(), testFeatureSchema, nil)
+		assert.Equal(t, unavailable, err)
+	}
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	_, err := breaker.ScoreModel(context.Background(), testFeatureSchema, nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, inner.calls, "an open breaker should not call the model server")
+}
+
+func TestCircuitBreakerScorer_recovers(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	inner := &scriptedScorer{errs: []error{unavailable, unavailable}}
+	breaker := NewCircuitBreakerScorer(inner, testBreakerConfig)
+	for i := 0; i < 2; i++ {
+		breaker.ScoreModel(context.Background(), testFeatureSchema, nil)
+	}
+
+	time.Sleep(testBreakerConfig.OpenTimeout)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	scores, err := breaker.ScoreModel(context.Background(), testFeatureSchema, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"score": 1.0}, scores)
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestCircuitBreakerScorer_failed_trial(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	inner := &scriptedScorer{errs: []error{unavailable, unavailable, unavailable}}
+	breaker := NewCircuitBreakerScorer(inner, testBreakerConfig)
+	for i := 0; i < 2; i++ {
+		breaker.ScoreModel(context.Background(), testFeatureSchema, nil)
+	}
+
+	time.Sleep(testBreakerConfig.OpenTimeout)
+	_, err := breaker.ScoreModel(context.Background(), testFeatureSchema, nil)
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, BreakerOpen, breaker.State(), "a failed trial should reopen the breaker")
+}
+
+func TestCircuitBreakerScorer_ignores_conversion_errors(t *testing.T) {
+	inner := &scriptedScorer{errs: []error{ErrConversion, ErrConversion, ErrConversion}}
+	breaker := NewCircuitBreakerScorer(inner, testBreakerConfig)
+	for i := 0; i < 3; i++ {
+		_, err := breaker.ScoreModel(context.Background(), testFeatureSchema, nil)
+		assert.ErrorIs(t, err, ErrConversion)
+	}
+	assert.Equal(t, BreakerClosed, breaker.State(), "bad features say nothing about the model server")
+}
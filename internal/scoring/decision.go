@@ -0,0 +1,73 @@
+package scoring
+
+import "context"
+
+// Decision describes how the scores for a request were produced, so that the
+// handler can annotate the response. Scorers fill it in as the request passes
+// through them.
+type Decision struct {
+	// Model is the model the event was routed to, if routing is in use.
+	Model string
+	// Variant is the variant of a traffic split the event was assigned to.
+	Variant string
+	// Fallback is set when the scores came from the fallback scorer rather than the model.
+	Fallback bool
+	// FallbackReason is the primary scorer's error when Fallback is set.
+	FallbackReason string
+}
+
+type decisionKey struct{}
+
+// WithDecision returns a context carrying a new Decision for scorers to fill in.
+func WithDecision(ctx context.Context) (context.Context, *Decision) {
+	decision := &Decision{}
+	return context.WithValue(ctx, decisionKey{}, decision), decision
+}
+
+// DecisionFrom returns the Decision carried by ctx, or nil if there is none.
+func DecisionFrom(ctx context.Context) *Decision {
+	decision, _ := ctx.Value(decisionKey{}).(*Decision)
+	return decision
+}
+
+type eventTypeKey struct{}
+
+// WithEventType returns a context carrying the type of the event being scored,
+// for scorers that route on it.
+func WithEventType(ctx context.Context, eventType string) context.Context {
+	return context.WithValue(ctx, eventTypeKey{}, eventType)
+}
+
+// EventTypeFrom returns the event type carried by ctx, or "" if there is none.
+func EventTypeFrom(ctx context.Context) string {
+	eventType, _ := ctx.Value(eventTypeKey{}).(string)
+	return eventType
+}
+
+type eventIDKey struct{}
+
+// WithEventID returns a context carrying the id of the event being scored, so
+// that results recorded out of band can be joined back to it.
+func WithEventID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, eventIDKey{}, id)
+}
+
+// EventIDFrom returns the event id carried by ctx, or "" if there is none.
+func EventIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(eventIDKey{}).(string)
+	return id
+}
+
+type attributesKey struct{}
+
+// WithAttributes returns a context carrying the CloudEvent attributes and
+// extensions of the event being scored, for scorers that split traffic on them.
+func WithAttributes(ctx context.Context, attributes map[string]string) context.Context {
+	return context.WithValue(ctx, attributesKey{}, attributes)
+}
+
+// AttributesFrom returns the event attributes carried by ctx, or nil if there are none.
+func AttributesFrom(ctx context.Context) map[string]string {
+	attributes, _ := ctx.Value(attributesKey{}).(map[string]string)
+	return attributes
+}
@@ -0,0 +1,132 @@
+package scoring
+
+import (
+	"context"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/flight"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"strings"
+	"time"
+)
+
+// ModelSchemas are the arrow schemas a model advertises for the features it
+// expects and the scores it returns. Either is nil if the server doesn't
+// advertise it.
+type ModelSchemas struct {
+	Input *arrow.Schema
+	Output *arrow.Schema
+}
+
+// SchemaMismatchError lists how a record differs from the schema advertised
+// by the model.
+type SchemaMismatchError struct {
+	// Record is "features" or "scores".
+	Record string
+	Problems []string
+}
+
+func (e *SchemaMismatchError) Error() string {
+	return fmt.Sprintf("%s don't match the model's schema: %s", e.Record, strings.Join(e.Problems, "; "))
+}
+
+// The schemas a model advertises are fetched with GetSchema, at its
+// descriptor path followed by one of these segments.
+const (
+	inputSchemaSegment = "input"
+	outputSchemaSegment = "output"
+)
+
+// Discover asks the flight server for the model's schemas and validates every
+// later request against them. Servers that don't advertise a schema are
+// trusted as before. A server that answers both paths with the same schema is
+// taken to be ignoring the path, and its schema to describe the features
+// only. On error the last discovered schemas are kept.
+func (s *FlightModelScorer) Discover(ctx context.Context) error {
+	input, err := s.fetchSchema(ctx, inputSchemaSegment)
+	if err != nil {
+		return err
+	}
+	output, err := s.fetchSchema(ctx, outputSchemaSegment)
+	if err != nil {
+		return err
+	}
+	if input != nil && output != nil && input.Equal(output) {
+		output = nil
+	}
+	s.mu.Lock()
+	s.schemas = ModelSchemas{Input: input, Output: output}
+	s.mu.Unlock()
+	return nil
+}
+
+// fetchSchema returns the schema advertised under segment, or nil if there is none.
+func (s *FlightModelScorer) fetchSchema(ctx context.Context, segment string) (*arrow.Schema, error) {
+	path := append(append([]string(nil), s.path...), segment)
+	result, err := s.client.GetSchema(ctx, &flight.FlightDescriptor{Type: flight.FlightDescriptor_PATH, Path: path})
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.Unimplemented, codes.NotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("GetSchema failed: model: %s, schema: %s, reason: %w", s.model(), segment, err)
+	}
+	schema, err := flight.DeserializeSchema(result.GetSchema(), memory.DefaultAllocator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s schema: model: %s, reason: %w", segment, s.model(), err)
+	}
+	return schema, nil
+}
+
+// WatchSchemas rediscovers the model's schemas every interval, each attempt
+// bounded by timeout, until the scorer is closed.
+func (s *FlightModelScorer) WatchSchemas(interval, timeout time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			err := s.Discover(ctx)
+			cancel()
+			if err != nil {
+				log.Printf("error refreshing model schemas: %s", err)
+			}
+		}
+	}()
+}
+
+// Schemas returns the schemas found by the last successful discovery.
+func (s *FlightModelScorer) Schemas() ModelSchemas {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.schemas
+}
+
+func (s *FlightModelScorer) model() string {
+	return strings.Join(s.path, "/")
+}
+
+// compareSchema lists the fields of expected that are missing from actual or
+// have another type. Extra fields and nullability are not checked.
+func compareSchema(expected, actual *arrow.Schema) []string {
+	var problems []string
+	for _, want := range expected.Fields() {
+		got, ok := actual.FieldsByName(want.Name)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("missing field %s of type %s", want.Name, want.Type))
+			continue
+		}
+		if !arrow.TypeEqual(want.Type, got[0].Type) {
+			problems = append(problems, fmt.Sprintf("field %s is %s, expected %s", want.Name, got[0].Type, want.Type))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,170 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/flight"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/ehenry2/avro-flight-decisioner/internal/arrowconv"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"testing"
+	"time"
+)
+
+// advertisingService serves doubleAmount and advertises the schemas it holds
+// for the model at path. A nil schema isn't advertised.
+type advertisingService struct {
+	path []string
+	mu sync.Mutex
+	input *arrow.Schema
+	output *arrow.Schema
+	err error
+}
+
+func (a *advertisingService) set(input, output *arrow.Schema, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.input, a.output, a.err = input, output, err
+}
+
+func (a *advertisingService) service() *flight.FlightServiceService {
+	return &flight.FlightServiceService{
+		DoExchange: doubleAmount,
+		GetSchema: func(ctx context.Context, d *flight.FlightDescriptor) (*flight.SchemaResult, error) {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if a.err != nil {
+				return nil, a.err
+			}
+			var schema *arrow.Schema
+			switch {
+			case assert.ObjectsAreEqual(append(a.path, "input"), d.Path):
+				schema = a.input
+			case assert.ObjectsAreEqual(append(a.path, "output"), d.Path):
+				schema = a.output
+			}
+			if schema == nil {
+				return nil, status.Errorf(codes.NotFound, "no schema at %v", d.Path)
+			}
+			return &flight.SchemaResult{Schema: flight.SerializeSchema(schema, memory.DefaultAllocator)}, nil
+		},
+	}
+}
+
+func newDiscoveringScorer(t *testing.T, svc *advertisingService) *FlightModelScorer {
+	client := startTestFlightService(t, svc.service())
+	scorer := NewFlightModelScorer(client, arrowconv.NewArrowConverter(memory.NewGoAllocator()), svc.path)
+	t.Cleanup(scorer.Close)
+	return scorer
+}
+
+func TestFlightModelScorer_Discover(t *testing.T) {
+	svc := &advertisingService{path: []string{"risk", "v2"}, input: testFeatureSchema, output: testScoreSchema}
+	scorer := newDiscoveringScorer(t, svc)
+
+	err := scorer.Discover(context.Background())
+	assert.Nil(t, err)
+	schemas := scorer.Schemas()
+	assert.True(t, testFeatureSchema.Equal(schemas.Input), "input schema: %s", schemas.Input)
+	assert.True(t, testScoreSchema.Equal(schemas.Output), "output schema: %s", schemas.Output)
+
+	scores, err := scorer.ScoreModel(context.Background(), testFeatureSchema, map[string]interface{}{"amount": 2.0, "id": "a"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"score": 4.0}, scores)
+}
+
+func TestFlightModelScorer_Discover_unimplemented(t *testing.T) {
+	client := startTestFlightServer(t, doubleAmount)
+	scorer := NewFlightModelScorer(client, arrowconv.NewArrowConverter(memory.NewGoAllocator()), nil)
+	defer scorer.Close()
+
+	assert.Nil(t, scorer.Discover(context.Background()))
+	assert.Equal(t, ModelSchemas{}, scorer.Schemas(), "nothing is validated without advertised schemas")
+}
+
+func TestFlightModelScorer_Discover_input_only(t *testing.T) {
+	svc := &advertisingService{path: []string{"risk"}, input: testFeatureSchema}
+	scorer := newDiscoveringScorer(t, svc)
+
+	assert.Nil(t, scorer.Discover(context.Background()))
+	assert.True(t, testFeatureSchema.Equal(scorer.Schemas().Input))
+	assert.Nil(t, scorer.Schemas().Output, "scores shouldn't be checked unless the model advertises them")
+	_, err := scorer.ScoreModel(context.Background(), testFeatureSchema, map[string]interface{}{"amount": 2.0, "id": "a"})
+	assert.Nil(t, err)
+}
+
+func TestFlightModelScorer_Discover_ignores_path(t *testing.T) {
+	// a standard server describing the flight's data whatever the path.
+	client := startTestFlightService(t, &flight.FlightServiceService{
+		DoExchange: doubleAmount,
+		GetSchema: func(ctx context.Context, d *flight.FlightDescriptor) (*flight.SchemaResult, error) {
+			return &flight.SchemaResult{Schema: flight.SerializeSchema(testFeatureSchema, memory.DefaultAllocator)}, nil
+		},
+	})
+	scorer := NewFlightModelScorer(client, arrowconv.NewArrowConverter(memory.NewGoAllocator()), []string{"risk"})
+	defer scorer.Close()
+
+	assert.Nil(t, scorer.Discover(context.Background()))
+	assert.True(t, testFeatureSchema.Equal(scorer.Schemas().Input))
+	assert.Nil(t, scorer.Schemas().Output, "the same schema twice describes the features")
+	scores, err := scorer.ScoreModel(context.Background(), testFeatureSchema, map[string]interface{}{"amount": 2.0, "id": "a"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"score": 4.0}, scores)
+}
+
+func TestFlightModelScorer_Discover_keeps_schemas_on_error(t *testing.T) {
+	svc := &advertisingService{path: []string{"risk"}, input: testFeatureSchema, output: testScoreSchema}
+	scorer := newDiscoveringScorer(t, svc)
+	assert.Nil(t, scorer.Discover(context.Background()))
+
+	svc.set(nil, nil, status.Error(codes.Unavailable, "restarting"))
+	assert.Error(t, scorer.Discover(context.Background()))
+	assert.NotNil(t, scorer.Schemas().Input, "the last discovered schemas should be kept")
+}
+
+func TestFlightModelScorer_feature_mismatch(t *testing.T) {
+	expected := arrow.NewSchema([]arrow.Field{
+		{Name: "amount", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "country", Type: arrow.BinaryTypes.String},
+	}, nil)
+	svc := &advertisingService{path: []string{"risk"}, input: expected, output: testScoreSchema}
+	scorer := newDiscoveringScorer(t, svc)
+	assert.Nil(t, scorer.Discover(context.Background()))
+
+	_, err := scorer.ScoreModel(context.Background(), testFeatureSchema, map[string]interface{}{"amount": 2.0, "id": "a"})
+	assert.True(t, errors.Is(err, ErrConversion), "expected a conversion error, got %v", err)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "field amount is float64, expected int64")
+		assert.Contains(t, err.Error(), "missing field country of type utf8")
+	}
+}
+
+func TestFlightModelScorer_score_mismatch(t *testing.T) {
+	expected := arrow.NewSchema([]arrow.Field{{Name: "probability", Type: arrow.PrimitiveTypes.Float64}}, nil)
+	svc := &advertisingService{path: []string{"risk"}, input: testFeatureSchema, output: expected}
+	scorer := newDiscoveringScorer(t, svc)
+	assert.Nil(t, scorer.Discover(context.Background()))
+
+	_, err := scorer.ScoreModel(context.Background(), testFeatureSchema, map[string]interface{}{"amount": 2.0, "id": "a"})
+	var mismatch *SchemaMismatchError
+	if assert.True(t, errors.As(err, &mismatch), "expected a schema mismatch, got %v", err) {
+		assert.Equal(t, "scores", mismatch.Record)
+		assert.Equal(t, []string{"missing field probability of type float64"}, mismatch.Problems)
+	}
+	assert.False(t, errors.Is(err, ErrConversion), "the model returning the wrong scores is not a conversion error")
+}
+
+func TestFlightModelScorer_WatchSchemas(t *testing.T) {
+	svc := &advertisingService{path: []string{"risk"}, input: testFeatureSchema, output: testScoreSchema}
+	scorer := newDiscoveringScorer(t, svc)
+	scorer.WatchSchemas(5*time.Millisecond, time.Second)
+
+	assert.Eventually(t, func() bool { return scorer.Schemas().Input != nil }, time.Second, 5*time.Millisecond)
+	updated := arrow.NewSchema([]arrow.Field{{Name: "amount", Type: arrow.PrimitiveTypes.Float64}}, nil)
+	svc.set(updated, testScoreSchema, nil)
+	assert.Eventually(t, func() bool { return updated.Equal(scorer.Schemas().Input) }, time.Second, 5*time.Millisecond)
+}
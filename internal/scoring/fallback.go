@@ -0,0 +1,92 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"google.golang.org/grpc/status"
+	"log"
+	"time"
+)
+
+// FallbackScorer scores with the fallback when the model behind the primary
+// scorer fails, so that an event still gets a decision while the model server
+// is down. Only model failures fall back: errors from the server, the primary
+// running out of time, an open circuit breaker or scores that don't match the
+// model's schema. Errors in the event or the configuration, such as features
+// that don't convert or an event type with no route, are returned as they are.
+// The decision in the context is flagged whenever the fallback was used.
+type FallbackScorer struct {
+	primary ModelScorer
+	fallback ModelScorer
+	// primaryTimeout bounds the primary scorer so that the rest of the
+	// request's deadline is left for the fallback. Zero means no bound.
+	primaryTimeout time.Duration
+}
+
+func NewFallbackScorer(primary, fallback ModelScorer, primaryTimeout time.Duration) *FallbackScorer {
+	return &FallbackScorer{primary, fallback, primaryTimeout}
+}
+
+func (s *FallbackScorer) ScoreModel(ctx context.Context, schema *arrow.Schema, features map[string]interface{}) (map[string]interface{}, error) {
+	primaryCtx := ctx
+	if s.primaryTimeout > 0 {
+		var cancel context.CancelFunc
+		primaryCtx, cancel = context.WithTimeout(ctx, s.primaryTimeout)
+		defer cancel()
+	}
+	scores, err := s.primary.ScoreModel(primaryCtx, schema, features)
+	if err == nil {
+		return scores, nil
+	}
+	if !isModelFailure(err) {
+		return nil, err
+	}
+
+	log.Printf("primary scorer failed, using fallback: reason: %s", err)
+	scores, fallbackErr := s.fallback.ScoreModel(ctx, schema, features)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("fallback failed: %w, primary error: %s", fallbackErr, err)
+	}
+	if decision := DecisionFrom(ctx); decision != nil {
+		decision.Fallback = true
+		decision.FallbackReason = err.Error()
+	}
+	return scores, nil
+}
+
+// isModelFailure reports whether err came from the model rather than from the
+// event or the configuration.
+func isModelFailure(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	var mismatch *SchemaMismatchError
+	if errors.As(err, &mismatch) {
+		return true
+	}
+	_, ok := status.FromError(err)
+	return ok
+}
+
+// StaticScorer returns the same default scores for every request.
+type StaticScorer struct {
+	scores map[string]interface{}
+}
+
+func NewStaticScorer(scores map[string]interface{}) *StaticScorer {
+	return &StaticScorer{scores}
+}
+
+func (s *StaticScorer) ScoreModel(ctx context.Context, schema *arrow.Schema, features map[string]interface{}) (map[string]interface{}, error) {
+	return copyScores(s.scores), nil
+}
+
+func copyScores(scores map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(scores))
+	for k, v := range scores {
+		out[k] = v
+	}
+	return out
+}
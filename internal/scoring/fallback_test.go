@@ -0,0 +1,110 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+var testFallbackScores = map[string]interface{}{"score": 0.5}
+
+func TestFallbackScorer_primary_succeeds(t *testing.T) {
+	scorer := NewFallbackScorer(&scriptedScorer{}, NewStaticScorer(testFallbackScores), 0)
+	ctx, decision := WithDecision(context.Background())
+	scores, err := scorer.ScoreModel(ctx, testFeatureSchema, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"score": 1.0}, scores)
+	assert.False(t, decision.Fallback, "the model's scores are not a fallback decision")
+}
+
+func TestFallbackScorer_primary_fails(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	primary := &scriptedScorer{errs: []error{unavailable}}
+	scorer := NewFallbackScorer(primary, NewStaticScorer(testFallbackScores), 0)
+	ctx, decision := WithDecision(context.Background())
+	scores, err := scorer.ScoreModel(ctx, testFeatureSchema, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, testFallbackScores, scores)
+	assert.True(t, decision.Fallback, "the decision should be flagged as a fallback")
+	assert.Equal(t, unavailable.Error(), decision.FallbackReason)
+}
+
+func TestFallbackScorer_model_failures(t *testing.T) {
+	failures := map[string]error{
+		"server error": status.Error(codes.Internal, "model crashed"),
+		"deadline": context.DeadlineExceeded,
+		"circuit open": ErrCircuitOpen,
+		"scores mismatch": &SchemaMismatchError{Record: "scores", Problems: []string{"missing field score"}},
+	}
+	for name, failure := range failures {
+		t.Run(name, func(t *testing.T) {
+			primary := &scriptedScorer{errs: []error{failure}}
+			scorer := NewFallbackScorer(primary, NewStaticScorer(testFallbackScores), 0)
+			ctx, decision := WithDecision(context.Background())
+			scores, err := scorer.ScoreModel(ctx, testFeatureSchema, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, testFallbackScores, scores)
+			assert.True(t, decision.Fallback, "a model failure should fall back")
+		})
+	}
+}
+
+func TestFallbackScorer_passes_through_request_errors(t *testing.T) {
+	failures := map[string]error{
+		"no route": fmt.Errorf("%w: custom.unknown", ErrNoRoute),
+		"conversion": &featureError{err: errors.New("field amount is not nullable")},
+		"cancelled": context.Canceled,
+	}
+	for name, failure := range failures {
+		t.Run(name, func(t *testing.T) {
+			primary := &scriptedScorer{errs: []error{failure}}
+			scorer := NewFallbackScorer(primary, NewStaticScorer(testFallbackScores), 0)
+			ctx, decision := WithDecision(context.Background())
+			scores, err := scorer.ScoreModel(ctx, testFeatureSchema, nil)
+			assert.Nil(t, scores)
+			assert.Equal(t, failure, err, "an error that isn't the model's should be returned as it is")
+			assert.False(t, decision.Fallback, "an error that isn't the model's should not fall back")
+		})
+	}
+}
+
+// slowScorer waits for its context to be done.
+type slowScorer struct{}
+
+func (s slowScorer) ScoreModel(ctx context.Context, schema *arrow.Schema, features map[string]interface{}) (map[string]interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestFallbackScorer_primary_times_out(t *testing.T) {
+	scorer := NewFallbackScorer(slowScorer{}, NewStaticScorer(testFallbackScores), 10*time.Millisecond)
+	ctx, decision := WithDecision(context.Background())
+	scores, err := scorer.ScoreModel(ctx, testFeatureSchema, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, testFallbackScores, scores)
+	assert.True(t, decision.Fallback, "the decision should be flagged as a fallback")
+}
+
+func TestFallbackScorer_fallback_fails(t *testing.T) {
+	primaryErr := status.Error(codes.Internal, "stream closed")
+	primary := &scriptedScorer{errs: []error{primaryErr}}
+	scorer := NewFallbackScorer(primary, &RulesScorer{}, 0)
+	ctx, decision := WithDecision(context.Background())
+	_, err := scorer.ScoreModel(ctx, testFeatureSchema, nil)
+	assert.ErrorIs(t, err, ErrNoRuleMatched)
+	assert.Contains(t, err.Error(), primaryErr.Error(), "the primary error should not be lost")
+	assert.False(t, decision.Fallback, "a failed fallback is not a decision")
+}
+
+func TestStaticScorer_copies_scores(t *testing.T) {
+	scorer := NewStaticScorer(testFallbackScores)
+	scores, _ := scorer.ScoreModel(context.Background(), testFeatureSchema, nil)
+	scores["score"] = 1.0
+	assert.Equal(t, 0.5, testFallbackScores["score"], "callers should not be able to change the defaults")
+}
@@ -0,0 +1,73 @@
+package scoring
+
+import (
+	"context"
+	"github.com/apache/arrow/go/v7/arrow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how transient model server failures are retried.
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt, so 1 disables retries.
+	MaxAttempts int
+	InitialBackoff time.Duration
+	MaxBackoff time.Duration
+	Multiplier float64
+}
+
+// RetryingScorer retries scoring when the model server reports it is
+// unavailable or overloaded. Other failures are returned straight away.
+type RetryingScorer struct {
+	scorer ModelScorer
+	policy RetryPolicy
+}
+
+func NewRetryingScorer(scorer ModelScorer, policy RetryPolicy) *RetryingScorer {
+	return &RetryingScorer{scorer, policy}
+}
+
+func (s *RetryingScorer) ScoreModel(ctx context.Context, schema *arrow.Schema, features map[string]interface{}) (map[string]interface{}, error) {
+	var scores map[string]interface{}
+	var err error
+	for attempt := 0; ; attempt++ {
+		scores, err = s.scorer.ScoreModel(ctx, schema, features)
+		if err == nil || !isTransient(err) || attempt+1 >= s.policy.MaxAttempts {
+			return scores, err
+		}
+		timer := time.NewTimer(s.policy.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// backoff returns a random wait of up to the exponential backoff for the
+// attempt, so that retries from many events spread out.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff)
+	for i := 0; i < attempt; i++ {
+		backoff *= p.Multiplier
+	}
+	if max := float64(p.MaxBackoff); p.MaxBackoff > 0 && backoff > max {
+		backoff = max
+	}
+	if backoff < 1 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff)))
+}
+
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
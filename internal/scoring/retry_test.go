@@ -0,0 +1,104 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedScorer fails with each of its errors in turn, then succeeds.
+type scriptedScorer struct {
+	mu sync.Mutex
+	errs []error
+	calls int
+}
+
+func (s *scriptedScorer) ScoreModel(ctx context.Context, schema *arrow.Schema, features map[string]interface{}) (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return nil, err
+	}
+	return map[string]interface{}{"score": 1.0}, nil
+}
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff: 5 * time.Millisecond,
+	Multiplier: 2,
+}
+
+func TestRetryingScorer_retries_transient(t *testing.T) {
+	inner := &scriptedScorer{errs: []error{
+		status.Error(codes.Unavailable, "connection refused"),
+		status.Error(codes.ResourceExhausted, "too many requests"),
+	}}
+	scorer := NewRetryingScorer(inner, testRetryPolicy)
+	scores, err := scorer.ScoreModel(context.Background(), testFeatureSchema, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"score": 1.0}, scores)
+	assert.Equal(t, 3, inner.calls)
+}
+
+func TestRetryingScorer_gives_up(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	inner := &scriptedScorer{errs: []error{unavailable, unavailable, unavailable, unavailable}}
+	scorer := NewRetryingScorer(inner, testRetryPolicy)
+	_, err := scorer.ScoreModel(context.Background(), testFeatureSchema, nil)
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, testRetryPolicy.MaxAttempts, inner.calls)
+}
+
+func TestRetryingScorer_permanent_error(t *testing.T) {
+	tests := []struct {
+		name string
+		err error
+	}{
+		{"internal", status.Error(codes.Internal, "model blew up")},
+		{"conversion", ErrConversion},
+		{"not grpc", errors.New("stream closed")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &scriptedScorer{errs: []error{tt.err}}
+			scorer := NewRetryingScorer(inner, testRetryPolicy)
+			_, err := scorer.ScoreModel(context.Background(), testFeatureSchema, nil)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, 1, inner.calls, "a permanent error should not be retried")
+		})
+	}
+}
+
+func TestRetryingScorer_stops_on_deadline(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	inner := &scriptedScorer{errs: []error{unavailable, unavailable}}
+	policy := testRetryPolicy
+	policy.InitialBackoff, policy.MaxBackoff = time.Hour, time.Hour
+	scorer := NewRetryingScorer(inner, policy)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := scorer.ScoreModel(ctx, testFeatureSchema, nil)
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, 1, inner.calls, "the backoff should not outlive the request")
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond, Multiplier: 2}
+	for attempt, max := range []time.Duration{10, 20, 40, 40} {
+		for i := 0; i < 20; i++ {
+			backoff := policy.backoff(attempt)
+			assert.GreaterOrEqual(t, backoff, time.Duration(0))
+			assert.Less(t, backoff, max*time.Millisecond, "attempt %d should back off less than %dms", attempt, max)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"path"
+)
+
+// ErrNoRoute is returned for an event type that no route matches.
+var ErrNoRoute = errors.New("no model route for event type")
+
+// Route sends events of a type to a model. EventType is either an exact
+// CloudEvent type or a path.Match pattern such as "urm_6.*.feature".
+type Route struct {
+	EventType string
+	Model string
+	Scorer ModelScorer
+}
+
+// Router scores each event with the model routed to by its type, read from
+// the context. Exact routes win over patterns, and patterns are tried in
+// order. The chosen model is recorded on the decision.
+type Router struct {
+	exact map[string]Route
+	patterns []Route
+}
+
+// NewRouter rejects routes without a scorer, exact routes given twice and
+// patterns path.Match can't parse.
+func NewRouter(routes []Route) (*Router, error) {
+	r := &Router{exact: make(map[string]Route)}
+	for i, route := range routes {
+		if route.Scorer == nil {
+			return nil, fmt.Errorf("route %d: a scorer is required", i)
+		}
+		if !isPattern(route.EventType) {
+			if _, ok := r.exact[route.EventType]; ok {
+				return nil, fmt.Errorf("route %d: duplicate route for event type %s", i, route.EventType)
+			}
+			r.exact[route.EventType] = route
+			continue
+		}
+		if _, err := path.Match(route.EventType, ""); err != nil {
+			return nil, fmt.Errorf("route %d: invalid pattern %q: %w", i, route.EventType, err)
+		}
+		r.patterns = append(r.patterns, route)
+	}
+	return r, nil
+}
+
+// Lookup returns the route for the event type.
+func (r *Router) Lookup(eventType string) (Route, bool) {
+	if route, ok := r.exact[eventType]; ok {
+		return route, true
+	}
+	for _, route := range r.patterns {
+		if ok, _ := path.Match(route.EventType, eventType); ok {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+func (r *Router) ScoreModel(ctx context.Context, schema *arrow.Schema, features map[string]interface{}) (map[string]interface{}, error) {
+	eventType := EventTypeFrom(ctx)
+	route, ok := r.Lookup(eventType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoRoute, eventType)
+	}
+	if decision := DecisionFrom(ctx); decision != nil {
+		decision.Model = route.Model
+	}
+	return route.Scorer.ScoreModel(ctx, schema, features)
+}
+
+func isPattern(eventType string) bool {
+	for _, c := range eventType {
+		switch c {
+		case '*', '?', '[', '\\':
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTestRouter(t *testing.T, routes ...Route) *Router {
+	router, err := NewRouter(routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func routeTo(eventType, model string, score float64) Route {
+	return Route{EventType: eventType, Model: model, Scorer: NewStaticScorer(map[string]interface{}{"score": score})}
+}
+
+func TestRouter_ScoreModel(t *testing.T) {
+	router := newTestRouter(t,
+		routeTo("urm_6.*.feature", "risk", 0.1),
+		routeTo("urm_6.fraud.feature", "fraud", 0.2),
+		routeTo("urm_*", "catch-all", 0.3),
+	)
+	tests := []struct {
+		eventType string
+		model string
+		score float64
+	}{
+		{"urm_6.fraud.feature", "fraud", 0.2},
+		{"urm_6.credit.feature", "risk", 0.1},
+		{"urm_7", "catch-all", 0.3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.eventType, func(t *testing.T) {
+			ctx, decision := WithDecision(WithEventType(context.Background(), tt.eventType))
+			scores, err := router.ScoreModel(ctx, testFeatureSchema, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, map[string]interface{}{"score": tt.score}, scores)
+			assert.Equal(t, tt.model, decision.Model)
+		})
+	}
+}
+
+func TestRouter_ScoreModel_no_route(t *testing.T) {
+	router := newTestRouter(t, routeTo("urm_6.fraud.feature", "fraud", 0.2))
+	ctx, decision := WithDecision(WithEventType(context.Background(), "urm_6.credit.feature"))
+	_, err := router.ScoreModel(ctx, testFeatureSchema, nil)
+	assert.True(t, errors.Is(err, ErrNoRoute), "expected ErrNoRoute, got %v", err)
+	assert.Empty(t, decision.Model)
+}
+
+func TestNewRouter_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		routes []Route
+	}{
+		{"missing scorer", []Route{{EventType: "a", Model: "m"}}},
+		{"duplicate", []Route{routeTo("a", "m", 0), routeTo("a", "n", 0)}},
+		{"bad pattern", []Route{routeTo("a.[feature", "m", 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRouter(tt.routes)
+			assert.Error(t, err)
+		})
+	}
+}
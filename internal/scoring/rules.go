@@ -0,0 +1,154 @@
+package scoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"io/ioutil"
+	"reflect"
+)
+
+// ErrNoRuleMatched is returned when no rule matches and the table has no default.
+var ErrNoRuleMatched = errors.New("no fallback rule matched")
+
+// RulesTable is a list of rules checked in order. The scores of the first rule
+// whose conditions all hold are returned, or Default if none do. In JSON:
+//
+//	{
+//	  "rules": [
+//	    {"when": [{"field": "credit_score", "op": "<", "value": 600}], "scores": {"score": 0.9}}
+//	  ],
+//	  "default": {"score": 0.5}
+//	}
+type RulesTable struct {
+	Rules []Rule `json:"rules"`
+	Default map[string]interface{} `json:"default"`
+}
+
+type Rule struct {
+	When []Condition `json:"when"`
+	Scores map[string]interface{} `json:"scores"`
+}
+
+// Condition compares a top level feature to a value. Numbers support ==, !=,
+// <, <=, > and >=, any other value only == and !=. A missing feature never
+// matches, and a nullable feature is compared by its value.
+type Condition struct {
+	Field string `json:"field"`
+	Op string `json:"op"`
+	Value interface{} `json:"value"`
+}
+
+// RulesScorer scores features against a rules table.
+type RulesScorer struct {
+	table RulesTable
+}
+
+func NewRulesScorer(table RulesTable) (*RulesScorer, error) {
+	for i, rule := range table.Rules {
+		for _, cond := range rule.When {
+			switch cond.Op {
+			case "==", "!=", "<", "<=", ">", ">=":
+			default:
+				return nil, fmt.Errorf("rule %d: unknown operator %q", i, cond.Op)
+			}
+		}
+	}
+	return &RulesScorer{table}, nil
+}
+
+// LoadRulesScorer reads a JSON rules table from a file.
+func LoadRulesScorer(path string) (*RulesScorer, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var table RulesTable
+	err = json.Unmarshal(b, &table)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing rules table: file: %s, reason: %w", path, err)
+	}
+	return NewRulesScorer(table)
+}
+
+func (s *RulesScorer) ScoreModel(ctx context.Context, schema *arrow.Schema, features map[string]interface{}) (map[string]interface{}, error) {
+	for _, rule := range s.table.Rules {
+		if rule.matches(features) {
+			return copyScores(rule.Scores), nil
+		}
+	}
+	if s.table.Default == nil {
+		return nil, ErrNoRuleMatched
+	}
+	return copyScores(s.table.Default), nil
+}
+
+func (r Rule) matches(features map[string]interface{}) bool {
+	for _, cond := range r.When {
+		val, ok := features[cond.Field]
+		if !ok || !cond.holds(val) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Condition) holds(val interface{}) bool {
+	val = unwrapUnion(val)
+	a, aNum := toFloat(val)
+	b, bNum := toFloat(c.Value)
+	if aNum && bNum {
+		switch c.Op {
+		case "==":
+			return a == b
+		case "!=":
+			return a != b
+		case "<":
+			return a < b
+		case "<=":
+			return a <= b
+		case ">":
+			return a > b
+		case ">=":
+			return a >= b
+		}
+		return false
+	}
+	switch c.Op {
+	case "==":
+		return reflect.DeepEqual(val, c.Value)
+	case "!=":
+		return !reflect.DeepEqual(val, c.Value)
+	}
+	return false
+}
+
+// unwrapUnion returns the value of a non-null union, which goavro decodes as
+// {branch: value}.
+func unwrapUnion(val interface{}) interface{} {
+	if union, ok := val.(map[string]interface{}); ok && len(union) == 1 {
+		for _, v := range union {
+			return v
+		}
+	}
+	return val
+}
+
+// toFloat widens the numeric types goavro decodes to, for comparison with
+// the float64 values that JSON rules decode to.
+func toFloat(val interface{}) (float64, bool) {
+	switch n := val.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
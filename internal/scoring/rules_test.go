@@ -0,0 +1,71 @@
+package scoring
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const testRulesTable = `{
+  "rules": [
+    {"when": [{"field": "credit_score", "op": "<", "value": 600}], "scores": {"score": 0.9}},
+    {"when": [{"field": "state", "op": "==", "value": "NY"}, {"field": "balance", "op": ">=", "value": 1000}], "scores": {"score": 0.2}}
+  ],
+  "default": {"score": 0.5}
+}`
+
+func writeRulesFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	err := ioutil.WriteFile(path, []byte(contents), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRulesScorer_ScoreModel(t *testing.T) {
+	scorer, err := LoadRulesScorer(writeRulesFile(t, testRulesTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		features map[string]interface{}
+		score float64
+	}{
+		{"first rule", map[string]interface{}{"credit_score": int32(550), "state": "NY", "balance": 5000.0}, 0.9},
+		{"all conditions", map[string]interface{}{"credit_score": int32(700), "state": "NY", "balance": 5000.0}, 0.2},
+		{"one condition", map[string]interface{}{"credit_score": int32(700), "state": "NY", "balance": 10.0}, 0.5},
+		{"nullable feature", map[string]interface{}{"credit_score": map[string]interface{}{"int": int32(550)}}, 0.9},
+		{"missing feature", map[string]interface{}{"state": "NY"}, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores, err := scorer.ScoreModel(context.Background(), testFeatureSchema, tt.features)
+			assert.Nil(t, err)
+			assert.Equal(t, map[string]interface{}{"score": tt.score}, scores)
+		})
+	}
+}
+
+func TestRulesScorer_no_default(t *testing.T) {
+	scorer, err := NewRulesScorer(RulesTable{Rules: []Rule{
+		{When: []Condition{{Field: "state", Op: "!=", Value: "NY"}}, Scores: map[string]interface{}{"score": 0.1}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = scorer.ScoreModel(context.Background(), testFeatureSchema, map[string]interface{}{"state": "NY"})
+	assert.ErrorIs(t, err, ErrNoRuleMatched)
+}
+
+func TestLoadRulesScorer_errors(t *testing.T) {
+	_, err := LoadRulesScorer(writeRulesFile(t, `{"rules": [{"when": [{"field": "a", "op": "~", "value": 1}]}]}`))
+	assert.Error(t, err, "an unknown operator should be rejected")
+	_, err = LoadRulesScorer(writeRulesFile(t, `{"rules": `))
+	assert.Error(t, err, "invalid json should be rejected")
+	_, err = LoadRulesScorer(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err, "a missing file should be rejected")
+}
@@ -2,63 +2,140 @@ package scoring
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
 	"github.com/apache/arrow/go/v7/arrow/flight"
-	"github.com/apache/arrow/go/v7/arrow/ipc"
 	"github.com/ehenry2/avro-flight-decisioner/internal/arrowconv"
+	"sync"
 )
 
+// ErrConversion wraps any failure converting features to, or scores from, arrow.
+var ErrConversion = errors.New("arrow conversion failed")
 
+// featureError is an ErrConversion for features that couldn't be converted.
+// It keeps the underlying error reachable, so that a *arrowconv.RowError
+// names the row that failed.
+type featureError struct {
+	err error
+}
+
+func (e *featureError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrConversion, e.err)
+}
+
+func (e *featureError) Is(target error) bool {
+	return target == ErrConversion
+}
+
+func (e *featureError) Unwrap() error {
+	return e.err
+}
+
+// ModelScorer scores one set of features. The schema describes the features
+// and is derived from their avro schema. Scoring is abandoned once the context
+// is done.
 type ModelScorer interface {
-	ScoreModel(map[string]interface{}) (map[string]interface{}, error)
+	ScoreModel(context.Context, *arrow.Schema, map[string]interface{}) (map[string]interface{}, error)
+}
+
+// BatchScorer scores several rows of features that share a schema, returning
+// one set of scores per row in the same order. A row of features that can't be
+// converted is reported with a *arrowconv.RowError naming the row.
+type BatchScorer interface {
+	ScoreBatch(context.Context, *arrow.Schema, []map[string]interface{}) ([]map[string]interface{}, error)
 }
 
 type ArrowFlightClient interface {
 	flight.FlightServiceClient
 }
 
+// FlightModelScorer scores features against a flight server over DoExchange.
+// The server must answer each record batch with one record batch of scores,
+// since exchange streams are kept open and reused across requests. Each
+// stream opens with a descriptor whose path names the model to score with.
+//
+// A model may advertise the features it expects and the scores it returns
+// with GetSchema, at its path followed by "input" and "output" respectively,
+// for example [risk v2 input]. Requests and responses are then checked
+// against whichever schemas it advertises.
 type FlightModelScorer struct {
 	client ArrowFlightClient
 	conv *arrowconv.ArrowConverter
+	path []string
+	streams *streamPool
+	mu sync.RWMutex
+	schemas ModelSchemas
+	done chan struct{}
+	closeOnce sync.Once
 }
 
-func NewFlightModelScorer(client ArrowFlightClient, conv *arrowconv.ArrowConverter) *FlightModelScorer {
-	return &FlightModelScorer{client, conv}
+// NewFlightModelScorer scores with the model at the given descriptor path. An
+// empty path is sent as a single empty segment, for servers hosting one model.
+func NewFlightModelScorer(client ArrowFlightClient, conv *arrowconv.ArrowConverter, path []string) *FlightModelScorer {
+	if len(path) == 0 {
+		path = []string{""}
+	}
+	return &FlightModelScorer{
+		client: client,
+		conv: conv,
+		path: path,
+		streams: newStreamPool(client, path, defaultStreamPoolSize),
+		done: make(chan struct{}),
+	}
 }
 
-func (s *FlightModelScorer) ScoreModel(features map[string]interface{}) (map[string]interface{}, error) {
-	featuresRecord, err := s.conv.MapToArrow(features)
-	if err != nil {
-		return nil, err
-	}
-	defer featuresRecord.Release()
+// LimitStreams retires each exchange stream once it reaches limits, so that
+// a balanced connection spreads requests over its replicas.
+func (s *FlightModelScorer) LimitStreams(limits StreamLimits) {
+	s.streams.setLimits(limits)
+}
 
-	dxc, err := s.client.DoExchange(context.Background())
+// Close shuts down the idle exchange streams and stops watching schemas.
+func (s *FlightModelScorer) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+	s.streams.close()
+}
+
+func (s *FlightModelScorer) ScoreModel(ctx context.Context, schema *arrow.Schema, features map[string]interface{}) (map[string]interface{}, error) {
+	scores, err := s.ScoreBatch(ctx, schema, []map[string]interface{}{features})
 	if err != nil {
 		return nil, err
 	}
-	writer := flight.NewRecordWriter(dxc, ipc.WithSchema(featuresRecord.Schema()))
-	writer.SetFlightDescriptor(&flight.FlightDescriptor{
-		Type: flight.FlightDescriptor_PATH,
-		Path: []string{""},
-	})
+	return scores[0], nil
+}
 
-	err = writer.Write(featuresRecord)
+func (s *FlightModelScorer) ScoreBatch(ctx context.Context, schema *arrow.Schema, features []map[string]interface{}) ([]map[string]interface{}, error) {
+	schemas := s.Schemas()
+	if schemas.Input != nil {
+		if problems := compareSchema(schemas.Input, schema); len(problems) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrConversion, &SchemaMismatchError{Record: "features", Problems: problems})
+		}
+	}
+	featuresRecord, err := s.conv.MapsToArrowWithSchema(features, schema)
 	if err != nil {
-		return nil, err
+		return nil, &featureError{err: err}
 	}
-	defer writer.Close()
+	defer featuresRecord.Release()
 
-	reader, err := flight.NewRecordReader(dxc)
+	outputRecord, err := s.streams.exchange(ctx, schema, featuresRecord)
 	if err != nil {
 		return nil, err
 	}
-	defer reader.Release()
-
-	outputRecord, err := reader.Read()
+	if outputRecord.NumRows() != int64(len(features)) {
+		outputRecord.Release()
+		return nil, fmt.Errorf("model returned %d rows of scores for %d rows of features",
+			outputRecord.NumRows(), len(features))
+	}
+	if schemas.Output != nil {
+		if problems := compareSchema(schemas.Output, outputRecord.Schema()); len(problems) > 0 {
+			outputRecord.Release()
+			return nil, &SchemaMismatchError{Record: "scores", Problems: problems}
+		}
+	}
+	scores, err := s.conv.ArrowToMaps(outputRecord)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %s", ErrConversion, err)
 	}
-	outputRecord.Retain()
-
-	return s.conv.ArrowToMap(outputRecord)
+	return scores, nil
 }
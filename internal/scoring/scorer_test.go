@@ -0,0 +1,148 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"github.com/apache/arrow/go/v7/arrow/flight"
+	"github.com/apache/arrow/go/v7/arrow/ipc"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/ehenry2/avro-flight-decisioner/internal/arrowconv"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"testing"
+)
+
+var (
+	testFeatureSchema = arrow.NewSchema([]arrow.Field{
+		{Name: "amount", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "id", Type: arrow.BinaryTypes.String},
+	}, nil)
+	testScoreSchema = arrow.NewSchema([]arrow.Field{
+		{Name: "score", Type: arrow.PrimitiveTypes.Float64},
+	}, nil)
+)
+
+// startTestFlightServer serves DoExchange in process and returns a client
+// connected to it.
+func startTestFlightServer(t *testing.T, doExchange func(flight.FlightService_DoExchangeServer) error) flight.FlightServiceClient {
+	return startTestFlightService(t, &flight.FlightServiceService{DoExchange: doExchange})
+}
+
+// startTestFlightService serves svc in process and returns a client connected to it.
+func startTestFlightService(t *testing.T, svc *flight.FlightServiceService) flight.FlightServiceClient {
+	t.Helper()
+	server := flight.NewServerWithMiddleware(nil, nil)
+	server.RegisterFlightService(svc)
+	if err := server.Init("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+
+	conn, err := grpc.Dial(server.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		server.Shutdown()
+	})
+	return flight.NewFlightServiceClient(conn)
+}
+
+// doubleAmount answers every record on the stream with a score of twice its amount.
+func doubleAmount(stream flight.FlightService_DoExchangeServer) error {
+	reader, err := flight.NewRecordReader(stream)
+	if err != nil {
+		return err
+	}
+	defer reader.Release()
+	writer := flight.NewRecordWriter(stream, ipc.WithSchema(testScoreSchema))
+	defer writer.Close()
+	for reader.Next() {
+		amounts := reader.Record().Column(0).(*array.Float64)
+		builder := array.NewRecordBuilder(memory.DefaultAllocator, testScoreSchema)
+		for i := 0; i < amounts.Len(); i++ {
+			builder.Field(0).(*array.Float64Builder).Append(amounts.Value(i) * 2)
+		}
+		record := builder.NewRecord()
+		builder.Release()
+		err = writer.Write(record)
+		record.Release()
+		if err != nil {
+			return err
+		}
+	}
+	return reader.Err()
+}
+
+func newTestScorer(t *testing.T, doExchange func(flight.FlightService_DoExchangeServer) error) *FlightModelScorer {
+	client := startTestFlightServer(t, doExchange)
+	return NewFlightModelScorer(client, arrowconv.NewArrowConverter(memory.NewGoAllocator()), nil)
+}
+
+func TestFlightModelScorer_ScoreModel(t *testing.T) {
+	scorer := newTestScorer(t, doubleAmount)
+	scores, err := scorer.ScoreModel(context.Background(), testFeatureSchema, map[string]interface{}{
+		"amount": 1.5,
+		"id": "a",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"score": 3.0}, scores)
+}
+
+func TestFlightModelScorer_ScoreBatch(t *testing.T) {
+	scorer := newTestScorer(t, doubleAmount)
+	features := []map[string]interface{}{
+		{"amount": 1.0, "id": "a"},
+		{"amount": 2.0, "id": "b"},
+		{"amount": 3.0, "id": "c"},
+	}
+	scores, err := scorer.ScoreBatch(context.Background(), testFeatureSchema, features)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"score": 2.0},
+		{"score": 4.0},
+		{"score": 6.0},
+	}, scores)
+}
+
+func TestFlightModelScorer_ScoreBatch_conversion_error(t *testing.T) {
+	scorer := newTestScorer(t, doubleAmount)
+	features := []map[string]interface{}{
+		{"amount": 1.0, "id": "a"},
+		{"amount": "not a number", "id": "b"},
+	}
+	_, err := scorer.ScoreBatch(context.Background(), testFeatureSchema, features)
+	assert.ErrorIs(t, err, ErrConversion)
+	var rowErr *arrowconv.RowError
+	if assert.True(t, errors.As(err, &rowErr), "the row error should be reachable") {
+		assert.Equal(t, 1, rowErr.Row)
+	}
+}
+
+func TestFlightModelScorer_ScoreBatch_row_mismatch(t *testing.T) {
+	scorer := newTestScorer(t, func(stream flight.FlightService_DoExchangeServer) error {
+		reader, err := flight.NewRecordReader(stream)
+		if err != nil {
+			return err
+		}
+		defer reader.Release()
+		writer := flight.NewRecordWriter(stream, ipc.WithSchema(testScoreSchema))
+		defer writer.Close()
+		reader.Next()
+		builder := array.NewRecordBuilder(memory.DefaultAllocator, testScoreSchema)
+		defer builder.Release()
+		builder.Field(0).(*array.Float64Builder).Append(1)
+		record := builder.NewRecord()
+		defer record.Release()
+		return writer.Write(record)
+	})
+	features := []map[string]interface{}{
+		{"amount": 1.0, "id": "a"},
+		{"amount": 2.0, "id": "b"},
+	}
+	_, err := scorer.ScoreBatch(context.Background(), testFeatureSchema, features)
+	assert.Error(t, err)
+}
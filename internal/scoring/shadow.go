@@ -0,0 +1,152 @@
+package scoring
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/apache/arrow/go/v7/arrow"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Challenger is a model scored in the shadow of the champion.
+type Challenger struct {
+	Name string
+	Scorer ModelScorer
+}
+
+// ShadowResult holds the champion's and one challenger's scores for an event.
+type ShadowResult struct {
+	Time time.Time `json:"time"`
+	EventID string `json:"eventId"`
+	EventType string `json:"eventType"`
+	ChampionModel string `json:"championModel,omitempty"`
+	ChampionFallback bool `json:"championFallback,omitempty"`
+	ChampionScores map[string]interface{} `json:"championScores,omitempty"`
+	ChampionError string `json:"championError,omitempty"`
+	ChampionMillis float64 `json:"championMillis"`
+	Challenger string `json:"challenger"`
+	ChallengerScores map[string]interface{} `json:"challengerScores,omitempty"`
+	ChallengerError string `json:"challengerError,omitempty"`
+	ChallengerMillis float64 `json:"challengerMillis"`
+}
+
+// ShadowRecorder stores shadow results for offline comparison. Record is
+// called concurrently.
+type ShadowRecorder interface {
+	Record(result ShadowResult)
+}
+
+// JSONLinesRecorder writes each shadow result as a line of JSON.
+type JSONLinesRecorder struct {
+	mu sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONLinesRecorder(w io.Writer) *JSONLinesRecorder {
+	return &JSONLinesRecorder{enc: json.NewEncoder(w)}
+}
+
+func (r *JSONLinesRecorder) Record(result ShadowResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.enc.Encode(result)
+	if err != nil {
+		log.Printf("error recording shadow result: event: %s, challenger: %s, reason: %s",
+			result.EventID, result.Challenger, err)
+	}
+}
+
+// ShadowScorer answers with the champion's scores and then scores the same
+// features against each challenger in the background, recording the results
+// side by side. Challengers never delay or fail the request. When maxInFlight
+// challenger calls are already running, new ones are dropped rather than
+// queued, so a slow challenger can't build up unbounded work.
+type ShadowScorer struct {
+	champion ModelScorer
+	challengers []Challenger
+	recorder ShadowRecorder
+	timeout time.Duration
+	slots chan struct{}
+	wg sync.WaitGroup
+}
+
+// NewShadowScorer bounds each challenger call by timeout, if positive.
+func NewShadowScorer(champion ModelScorer, challengers []Challenger, recorder ShadowRecorder,
+	timeout time.Duration, maxInFlight int) *ShadowScorer {
+	return &ShadowScorer{
+		champion: champion,
+		challengers: challengers,
+		recorder: recorder,
+		timeout: timeout,
+		slots: make(chan struct{}, maxInFlight),
+	}
+}
+
+func (s *ShadowScorer) ScoreModel(ctx context.Context, schema *arrow.Schema, features map[string]interface{}) (map[string]interface{}, error) {
+	start := time.Now()
+	scores, err := s.champion.ScoreModel(ctx, schema, features)
+	result := ShadowResult{
+		Time: start,
+		EventID: EventIDFrom(ctx),
+		EventType: EventTypeFrom(ctx),
+		ChampionMillis: millis(time.Since(start)),
+	}
+	if err != nil {
+		result.ChampionError = err.Error()
+	} else {
+		// the caller owns the returned map.
+		result.ChampionScores = copyScores(scores)
+	}
+	if decision := DecisionFrom(ctx); decision != nil {
+		result.ChampionModel = decision.Model
+		result.ChampionFallback = decision.Fallback
+	}
+	for _, challenger := range s.challengers {
+		s.shadow(challenger, schema, features, result)
+	}
+	return scores, err
+}
+
+// Wait blocks until every challenger call already started has been recorded.
+func (s *ShadowScorer) Wait() {
+	s.wg.Wait()
+}
+
+func (s *ShadowScorer) shadow(challenger Challenger, schema *arrow.Schema, features map[string]interface{}, result ShadowResult) {
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		log.Printf("too many shadow calls in flight, skipping challenger: event: %s, challenger: %s",
+			result.EventID, challenger.Name)
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.slots }()
+		// the request's context ends with the response, so the challenger
+		// gets its own, carrying only what routing needs.
+		ctx := WithEventType(context.Background(), result.EventType)
+		if s.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.timeout)
+			defer cancel()
+		}
+		start := time.Now()
+		scores, err := challenger.Scorer.ScoreModel(ctx, schema, features)
+		result.Challenger = challenger.Name
+		result.ChallengerMillis = millis(time.Since(start))
+		if err != nil {
+			result.ChallengerError = err.Error()
+		} else {
+			result.ChallengerScores = scores
+		}
+		s.recorder.Record(result)
+	}()
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,125 @@
+package scoring
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collectingRecorder keeps every shadow result in memory.
+type collectingRecorder struct {
+	mu sync.Mutex
+	results []ShadowResult
+}
+
+func (r *collectingRecorder) Record(result ShadowResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, result)
+}
+
+func (r *collectingRecorder) byChallenger() []ShadowResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := append([]ShadowResult(nil), r.results...)
+	sort.Slice(results, func(i, j int) bool { return results[i].Challenger < results[j].Challenger })
+	return results
+}
+
+func TestShadowScorer_records_side_by_side(t *testing.T) {
+	recorder := &collectingRecorder{}
+	scorer := NewShadowScorer(NewStaticScorer(map[string]interface{}{"score": 0.1}), []Challenger{
+		{Name: "v2", Scorer: NewStaticScorer(map[string]interface{}{"score": 0.2})},
+		{Name: "v3", Scorer: &scriptedScorer{errs: []error{errors.New("model not loaded")}}},
+	}, recorder, 0, 8)
+	ctx, decision := WithDecision(WithEventID(WithEventType(context.Background(), "urm_6.19.feature"), "abc123"))
+	decision.Model = "risk"
+
+	scores, err := scorer.ScoreModel(ctx, testFeatureSchema, nil)
+	scorer.Wait()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"score": 0.1}, scores)
+	results := recorder.byChallenger()
+	if !assert.Len(t, results, 2) {
+		return
+	}
+	for _, result := range results {
+		assert.Equal(t, "abc123", result.EventID)
+		assert.Equal(t, "urm_6.19.feature", result.EventType)
+		assert.Equal(t, "risk", result.ChampionModel)
+		assert.Equal(t, map[string]interface{}{"score": 0.1}, result.ChampionScores)
+	}
+	assert.Equal(t, "v2", results[0].Challenger)
+	assert.Equal(t, map[string]interface{}{"score": 0.2}, results[0].ChallengerScores)
+	assert.Equal(t, "v3", results[1].Challenger)
+	assert.Equal(t, "model not loaded", results[1].ChallengerError)
+}
+
+func TestShadowScorer_does_not_wait_for_challengers(t *testing.T) {
+	recorder := &collectingRecorder{}
+	scorer := NewShadowScorer(NewStaticScorer(map[string]interface{}{"score": 0.1}), []Challenger{
+		{Name: "slow", Scorer: slowScorer{}},
+	}, recorder, 50*time.Millisecond, 8)
+
+	start := time.Now()
+	_, err := scorer.ScoreModel(context.Background(), testFeatureSchema, nil)
+	assert.Nil(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(50*time.Millisecond), "the champion should not wait for the challenger")
+	scorer.Wait()
+	results := recorder.byChallenger()
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, context.DeadlineExceeded.Error(), results[0].ChallengerError)
+	}
+}
+
+func TestShadowScorer_champion_error(t *testing.T) {
+	recorder := &collectingRecorder{}
+	champion := &scriptedScorer{errs: []error{errors.New("model not loaded")}}
+	scorer := NewShadowScorer(champion, []Challenger{
+		{Name: "v2", Scorer: NewStaticScorer(map[string]interface{}{"score": 0.2})},
+	}, recorder, 0, 8)
+
+	_, err := scorer.ScoreModel(context.Background(), testFeatureSchema, nil)
+	scorer.Wait()
+	assert.EqualError(t, err, "model not loaded")
+	results := recorder.byChallenger()
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "model not loaded", results[0].ChampionError)
+		assert.Equal(t, map[string]interface{}{"score": 0.2}, results[0].ChallengerScores)
+	}
+}
+
+func TestShadowScorer_drops_when_busy(t *testing.T) {
+	recorder := &collectingRecorder{}
+	scorer := NewShadowScorer(NewStaticScorer(map[string]interface{}{"score": 0.1}), []Challenger{
+		{Name: "a", Scorer: slowScorer{}},
+		{Name: "b", Scorer: slowScorer{}},
+	}, recorder, 10*time.Millisecond, 1)
+
+	_, err := scorer.ScoreModel(context.Background(), testFeatureSchema, nil)
+	scorer.Wait()
+	assert.Nil(t, err)
+	assert.Len(t, recorder.byChallenger(), 1, "the second challenger should be skipped")
+}
+
+func TestJSONLinesRecorder_Record(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewJSONLinesRecorder(&buf)
+	recorder.Record(ShadowResult{EventID: "a", Challenger: "v2", ChallengerScores: map[string]interface{}{"score": 0.2}})
+	recorder.Record(ShadowResult{EventID: "b", Challenger: "v2", ChallengerError: "unavailable"})
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if !assert.Len(t, lines, 2) {
+		return
+	}
+	var result ShadowResult
+	assert.Nil(t, json.Unmarshal(lines[0], &result))
+	assert.Equal(t, "a", result.EventID)
+	assert.Equal(t, map[string]interface{}{"score": 0.2}, result.ChallengerScores)
+}
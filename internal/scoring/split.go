@@ -0,0 +1,101 @@
+package scoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"hash/fnv"
+	"math/rand"
+)
+
+// Variant is one side of a traffic split, receiving a share of events
+// proportional to its weight.
+type Variant struct {
+	Name string
+	Weight int
+	Scorer ModelScorer
+}
+
+// SplitKey names what a SplitScorer hashes to assign an event to a variant:
+// a CloudEvent attribute or extension, or a feature field.
+type SplitKey struct {
+	Attribute string
+	Field string
+}
+
+// SplitScorer splits traffic between variants by weight. Events with the same
+// key always go to the same variant while the weights are unchanged, so a
+// customer sees one model version throughout a rollout. Events without a key
+// are assigned at random. The chosen variant is recorded on the decision.
+type SplitScorer struct {
+	variants []Variant
+	total uint64
+	key SplitKey
+}
+
+// NewSplitScorer needs at least one variant with a positive weight. A variant
+// with no weight receives no traffic, which drains it without removing it.
+func NewSplitScorer(key SplitKey, variants []Variant) (*SplitScorer, error) {
+	if (key.Attribute == "") == (key.Field == "") {
+		return nil, errors.New("split key needs exactly one of an attribute or a field")
+	}
+	var total uint64
+	names := make(map[string]bool)
+	for i, variant := range variants {
+		if variant.Scorer == nil {
+			return nil, fmt.Errorf("variant %d: a scorer is required", i)
+		}
+		if variant.Weight < 0 {
+			return nil, fmt.Errorf("variant %d: weight must not be negative", i)
+		}
+		if names[variant.Name] {
+			return nil, fmt.Errorf("variant %d: duplicate variant %s", i, variant.Name)
+		}
+		names[variant.Name] = true
+		total += uint64(variant.Weight)
+	}
+	if total == 0 {
+		return nil, errors.New("a split needs a variant with a positive weight")
+	}
+	return &SplitScorer{variants: variants, total: total, key: key}, nil
+}
+
+func (s *SplitScorer) ScoreModel(ctx context.Context, schema *arrow.Schema, features map[string]interface{}) (map[string]interface{}, error) {
+	variant := s.assign(ctx, features)
+	if decision := DecisionFrom(ctx); decision != nil {
+		decision.Variant = variant.Name
+	}
+	return variant.Scorer.ScoreModel(ctx, schema, features)
+}
+
+func (s *SplitScorer) assign(ctx context.Context, features map[string]interface{}) Variant {
+	var point uint64
+	if key, ok := s.keyOf(ctx, features); ok {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		point = h.Sum64() % s.total
+	} else {
+		point = uint64(rand.Int63n(int64(s.total)))
+	}
+	for _, variant := range s.variants {
+		if point < uint64(variant.Weight) {
+			return variant
+		}
+		point -= uint64(variant.Weight)
+	}
+	// unreachable, the weights sum to total.
+	return s.variants[len(s.variants)-1]
+}
+
+func (s *SplitScorer) keyOf(ctx context.Context, features map[string]interface{}) (string, bool) {
+	if s.key.Attribute != "" {
+		key, ok := AttributesFrom(ctx)[s.key.Attribute]
+		return key, ok && key != ""
+	}
+	val := unwrapUnion(features[s.key.Field])
+	if val == nil {
+		return "", false
+	}
+	return fmt.Sprint(val), true
+}
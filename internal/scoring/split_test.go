@@ -0,0 +1,97 @@
+package scoring
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func variant(name string, weight int) Variant {
+	return Variant{Name: name, Weight: weight, Scorer: NewStaticScorer(map[string]interface{}{"model": name})}
+}
+
+func newTestSplit(t *testing.T, key SplitKey, variants ...Variant) *SplitScorer {
+	scorer, err := NewSplitScorer(key, variants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scorer
+}
+
+// scoreVariant scores the features and returns the variant recorded on the decision.
+func scoreVariant(t *testing.T, scorer *SplitScorer, ctx context.Context, features map[string]interface{}) string {
+	ctx, decision := WithDecision(ctx)
+	scores, err := scorer.ScoreModel(ctx, testFeatureSchema, features)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, decision.Variant, scores["model"], "the recorded variant should be the one that scored")
+	return decision.Variant
+}
+
+func TestSplitScorer_weights(t *testing.T) {
+	scorer := newTestSplit(t, SplitKey{Field: "app_id"}, variant("v1", 90), variant("v2", 10))
+	counts := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		counts[scoreVariant(t, scorer, context.Background(), map[string]interface{}{"app_id": fmt.Sprint(i)})]++
+	}
+	assert.InDelta(t, 1800, counts["v1"], 100)
+	assert.InDelta(t, 200, counts["v2"], 100)
+}
+
+func TestSplitScorer_sticky(t *testing.T) {
+	scorer := newTestSplit(t, SplitKey{Field: "app_id"}, variant("v1", 50), variant("v2", 50))
+	for i := 0; i < 20; i++ {
+		features := map[string]interface{}{"app_id": fmt.Sprint(i)}
+		first := scoreVariant(t, scorer, context.Background(), features)
+		for j := 0; j < 5; j++ {
+			assert.Equal(t, first, scoreVariant(t, scorer, context.Background(), features))
+		}
+		// a union wrapped key is the same key.
+		union := map[string]interface{}{"app_id": map[string]interface{}{"string": fmt.Sprint(i)}}
+		assert.Equal(t, first, scoreVariant(t, scorer, context.Background(), union))
+	}
+}
+
+func TestSplitScorer_attribute_key(t *testing.T) {
+	scorer := newTestSplit(t, SplitKey{Attribute: "subject"}, variant("v1", 50), variant("v2", 50))
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		ctx := WithAttributes(context.Background(), map[string]string{"subject": fmt.Sprint(i)})
+		v := scoreVariant(t, scorer, ctx, nil)
+		assert.Equal(t, v, scoreVariant(t, scorer, ctx, nil))
+		seen[v] = true
+	}
+	assert.Len(t, seen, 2, "keys should be spread across both variants")
+}
+
+func TestSplitScorer_drained_variant(t *testing.T) {
+	scorer := newTestSplit(t, SplitKey{Field: "app_id"}, variant("v1", 0), variant("v2", 1))
+	for i := 0; i < 50; i++ {
+		assert.Equal(t, "v2", scoreVariant(t, scorer, context.Background(), map[string]interface{}{"app_id": i}))
+	}
+	assert.Equal(t, "v2", scoreVariant(t, scorer, context.Background(), nil), "unkeyed events should respect weights")
+}
+
+func TestNewSplitScorer_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		key SplitKey
+		variants []Variant
+	}{
+		{"no key", SplitKey{}, []Variant{variant("v1", 1)}},
+		{"two keys", SplitKey{Attribute: "subject", Field: "app_id"}, []Variant{variant("v1", 1)}},
+		{"no variants", SplitKey{Field: "app_id"}, nil},
+		{"no weight", SplitKey{Field: "app_id"}, []Variant{variant("v1", 0)}},
+		{"negative weight", SplitKey{Field: "app_id"}, []Variant{variant("v1", 2), variant("v2", -1)}},
+		{"duplicate", SplitKey{Field: "app_id"}, []Variant{variant("v1", 1), variant("v1", 1)}},
+		{"missing scorer", SplitKey{Field: "app_id"}, []Variant{{Name: "v1", Weight: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSplitScorer(tt.key, tt.variants)
+			assert.Error(t, err)
+		})
+	}
+}
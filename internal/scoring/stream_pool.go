@@ -0,0 +1,238 @@
+package scoring
+
+import (
+	"context"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"github.com/apache/arrow/go/v7/arrow/flight"
+	"github.com/apache/arrow/go/v7/arrow/ipc"
+	"sync"
+	"time"
+)
+
+// defaultStreamPoolSize bounds the idle exchange streams kept per schema.
+const defaultStreamPoolSize = 8
+
+// StreamLimits retire a pooled exchange stream once it has served
+// MaxRequests requests or is MaxAge old, zero leaving either unlimited. A
+// balanced connection picks a replica per stream rather than per request, so
+// these bound how long traffic stays on one replica, including one that has
+// stopped serving.
+type StreamLimits struct {
+	MaxRequests int
+	MaxAge time.Duration
+}
+
+// exchangeStream is a long lived DoExchange call. Each record written to it
+// is answered by exactly one record, so a stream serves one request at a time.
+type exchangeStream struct {
+	dxc flight.FlightService_DoExchangeClient
+	writer *flight.Writer
+	reader *flight.Reader
+	cancel context.CancelFunc
+	opened time.Time
+	requests int
+}
+
+// openExchangeStream opens a stream that outlives the request, but opening
+// it, which may mean waiting on the connection or fetching a token, is
+// abandoned once ctx is done.
+func openExchangeStream(ctx context.Context, client ArrowFlightClient, path []string, schema *arrow.Schema) (*exchangeStream, error) {
+	streamCtx, cancel := context.WithCancel(context.Background())
+	stop := make(chan struct{})
+	aborted := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+			aborted <- true
+		case <-stop:
+			aborted <- false
+		}
+	}()
+
+	dxc, err := client.DoExchange(streamCtx)
+	close(stop)
+	if <-aborted {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	writer := flight.NewRecordWriter(dxc, ipc.WithSchema(schema))
+	writer.SetFlightDescriptor(&flight.FlightDescriptor{
+		Type: flight.FlightDescriptor_PATH,
+		Path: path,
+	})
+	return &exchangeStream{dxc: dxc, writer: writer, cancel: cancel, opened: time.Now()}, nil
+}
+
+// exchange writes the features and waits for the matching scores. If ctx is
+// done first the whole stream is cancelled, since a single message on a
+// stream can't be, and the stream must not be reused.
+func (s *exchangeStream) exchange(ctx context.Context, featuresRecord array.Record) (array.Record, error) {
+	stop := make(chan struct{})
+	aborted := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.cancel()
+			aborted <- true
+		case <-stop:
+			aborted <- false
+		}
+	}()
+
+	outputRecord, err := s.roundTrip(featuresRecord)
+	close(stop)
+	if <-aborted {
+		if outputRecord != nil {
+			outputRecord.Release()
+		}
+		return nil, ctx.Err()
+	}
+	return outputRecord, err
+}
+
+func (s *exchangeStream) roundTrip(featuresRecord array.Record) (array.Record, error) {
+	err := s.writer.Write(featuresRecord)
+	if err != nil {
+		return nil, err
+	}
+	// the reader blocks on the schema message, so it can only be created
+	// once the server has seen the first record.
+	if s.reader == nil {
+		s.reader, err = flight.NewRecordReader(s.dxc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	outputRecord, err := s.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	outputRecord.Retain()
+	return outputRecord, nil
+}
+
+func (s *exchangeStream) close() {
+	s.writer.Close()
+	s.dxc.CloseSend()
+	if s.reader != nil {
+		s.reader.Release()
+	}
+	s.cancel()
+}
+
+// streamPool keeps idle exchange streams per schema so that successive
+// requests skip the stream setup. Like the batching scorer it keys on the
+// schema pointer handed out by the codec loader.
+type streamPool struct {
+	client ArrowFlightClient
+	path []string
+	size int
+
+	mu sync.Mutex
+	limits StreamLimits
+	idle map[*arrow.Schema][]*exchangeStream
+}
+
+func newStreamPool(client ArrowFlightClient, path []string, size int) *streamPool {
+	return &streamPool{
+		client: client,
+		path: path,
+		size: size,
+		idle: make(map[*arrow.Schema][]*exchangeStream),
+	}
+}
+
+func (p *streamPool) setLimits(limits StreamLimits) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limits = limits
+}
+
+// expired reports whether the stream has reached its limits. p.mu must be held.
+func (p *streamPool) expired(stream *exchangeStream) bool {
+	return (p.limits.MaxRequests > 0 && stream.requests >= p.limits.MaxRequests) ||
+		(p.limits.MaxAge > 0 && time.Since(stream.opened) >= p.limits.MaxAge)
+}
+
+// get returns an idle stream for the schema, or opens a new one within ctx. reused
+// reports whether the stream came from the pool.
+func (p *streamPool) get(ctx context.Context, schema *arrow.Schema) (stream *exchangeStream, reused bool, err error) {
+	var expired []*exchangeStream
+	defer func() {
+		for _, old := range expired {
+			old.close()
+		}
+	}()
+	p.mu.Lock()
+	for streams := p.idle[schema]; len(streams) > 0; streams = p.idle[schema] {
+		stream = streams[len(streams)-1]
+		p.idle[schema] = streams[:len(streams)-1]
+		if !p.expired(stream) {
+			p.mu.Unlock()
+			return stream, true, nil
+		}
+		// streams age while idle.
+		expired = append(expired, stream)
+	}
+	p.mu.Unlock()
+	stream, err = openExchangeStream(ctx, p.client, p.path, schema)
+	return stream, false, err
+}
+
+// put returns a healthy stream to the pool, closing it if the pool is full or
+// the stream has reached its limits.
+func (p *streamPool) put(schema *arrow.Schema, stream *exchangeStream) {
+	p.mu.Lock()
+	stream.requests++
+	if len(p.idle[schema]) < p.size && !p.expired(stream) {
+		p.idle[schema] = append(p.idle[schema], stream)
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+	stream.close()
+}
+
+// exchange scores the record on a pooled stream. A stream that fails is
+// dropped. If it had sat idle in the pool the server may simply have closed
+// it, so the record is retried on the next stream until one that was freshly
+// opened fails.
+func (p *streamPool) exchange(ctx context.Context, schema *arrow.Schema, featuresRecord array.Record) (array.Record, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		stream, reused, err := p.get(ctx, schema)
+		if err != nil {
+			return nil, err
+		}
+		outputRecord, err := stream.exchange(ctx, featuresRecord)
+		if err != nil {
+			stream.close()
+			if reused && ctx.Err() == nil {
+				continue
+			}
+			return nil, err
+		}
+		p.put(schema, stream)
+		return outputRecord, nil
+	}
+}
+
+// close shuts down every idle stream.
+func (p *streamPool) close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = make(map[*arrow.Schema][]*exchangeStream)
+	p.mu.Unlock()
+	for _, streams := range idle {
+		for _, stream := range streams {
+			stream.close()
+		}
+	}
+}
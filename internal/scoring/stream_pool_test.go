@@ -0,0 +1,177 @@
+package scoring
+
+import (
+	"context"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"github.com/apache/arrow/go/v7/arrow/flight"
+	"github.com/apache/arrow/go/v7/arrow/ipc"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/ehenry2/avro-flight-decisioner/internal/arrowconv"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countStreams wraps a DoExchange handler, counting the streams it serves.
+func countStreams(count *int32, doExchange func(flight.FlightService_DoExchangeServer) error) func(flight.FlightService_DoExchangeServer) error {
+	return func(stream flight.FlightService_DoExchangeServer) error {
+		atomic.AddInt32(count, 1)
+		return doExchange(stream)
+	}
+}
+
+// scoreOnce answers the first record on a stream and then ends the stream,
+// like a server that drops idle connections.
+func scoreOnce(stream flight.FlightService_DoExchangeServer) error {
+	reader, err := flight.NewRecordReader(stream)
+	if err != nil {
+		return err
+	}
+	defer reader.Release()
+	writer := flight.NewRecordWriter(stream, ipc.WithSchema(testScoreSchema))
+	defer writer.Close()
+	if !reader.Next() {
+		return reader.Err()
+	}
+	amounts := reader.Record().Column(0).(*array.Float64)
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, testScoreSchema)
+	defer builder.Release()
+	for i := 0; i < amounts.Len(); i++ {
+		builder.Field(0).(*array.Float64Builder).Append(amounts.Value(i) * 2)
+	}
+	record := builder.NewRecord()
+	defer record.Release()
+	return writer.Write(record)
+}
+
+func TestFlightModelScorer_reuses_streams(t *testing.T) {
+	var streams int32
+	scorer := newTestScorer(t, countStreams(&streams, doubleAmount))
+	defer scorer.Close()
+	for i := 0; i < 5; i++ {
+		scores, err := scorer.ScoreModel(context.Background(), testFeatureSchema, map[string]interface{}{
+			"amount": float64(i),
+			"id": fmt.Sprint(i),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"score": float64(2 * i)}, scores)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&streams), "sequential requests should share one stream")
+}
+
+func TestFlightModelScorer_LimitStreams(t *testing.T) {
+	tests := []struct {
+		name string
+		limits StreamLimits
+		wait time.Duration
+		streams int32
+	}{
+		{"requests", StreamLimits{MaxRequests: 2}, 0, 3},
+		{"age", StreamLimits{MaxAge: 5 * time.Millisecond}, 10 * time.Millisecond, 5},
+		{"unlimited", StreamLimits{}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var streams int32
+			scorer := newTestScorer(t, countStreams(&streams, doubleAmount))
+			defer scorer.Close()
+			scorer.LimitStreams(tt.limits)
+			for i := 0; i < 5; i++ {
+				time.Sleep(tt.wait)
+				_, err := scorer.ScoreModel(context.Background(), testFeatureSchema, map[string]interface{}{
+					"amount": float64(i),
+					"id": fmt.Sprint(i),
+				})
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.streams, atomic.LoadInt32(&streams))
+		})
+	}
+}
+
+func TestFlightModelScorer_reestablishes_broken_streams(t *testing.T) {
+	var streams int32
+	scorer := newTestScorer(t, countStreams(&streams, scoreOnce))
+	defer scorer.Close()
+	for i := 0; i < 3; i++ {
+		scores, err := scorer.ScoreModel(context.Background(), testFeatureSchema, map[string]interface{}{
+			"amount": float64(i),
+			"id": fmt.Sprint(i),
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"score": float64(2 * i)}, scores)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&streams), "each broken stream should be replaced")
+}
+
+func TestFlightModelScorer_concurrent_streams(t *testing.T) {
+	scorer := newTestScorer(t, doubleAmount)
+	defer scorer.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			scores, err := scorer.ScoreModel(context.Background(), testFeatureSchema, map[string]interface{}{
+				"amount": float64(i),
+				"id": fmt.Sprint(i),
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, map[string]interface{}{"score": float64(2 * i)}, scores)
+		}(i)
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, len(scorer.streams.idle[testFeatureSchema]), defaultStreamPoolSize,
+		"the pool should keep at most its size of idle streams")
+}
+
+// hang reads the first record and never answers it.
+func hang(stream flight.FlightService_DoExchangeServer) error {
+	reader, err := flight.NewRecordReader(stream)
+	if err != nil {
+		return err
+	}
+	defer reader.Release()
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+func TestFlightModelScorer_ScoreModel_deadline(t *testing.T) {
+	scorer := newTestScorer(t, hang)
+	defer scorer.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := scorer.ScoreModel(ctx, testFeatureSchema, map[string]interface{}{
+		"amount": 1.0,
+		"id": "a",
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, scorer.streams.idle[testFeatureSchema], "an aborted stream should not be reused")
+}
+
+// stalledClient never finishes opening a stream, like a connection waiting on
+// a token from a stalled handshake.
+type stalledClient struct {
+	flight.FlightServiceClient
+}
+
+func (stalledClient) DoExchange(ctx context.Context, opts ...grpc.CallOption) (flight.FlightService_DoExchangeClient, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestFlightModelScorer_ScoreModel_deadline_opening_stream(t *testing.T) {
+	scorer := NewFlightModelScorer(stalledClient{}, arrowconv.NewArrowConverter(memory.NewGoAllocator()), nil)
+	defer scorer.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := scorer.ScoreModel(ctx, testFeatureSchema, map[string]interface{}{
+		"amount": 1.0,
+		"id": "a",
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,185 @@
+// Package tlsutil secures the flight client connection with certificates read
+// from PEM files, picking up rotated files without a restart.
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/credentials"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Files locates the PEM files for a TLS client. CAFile replaces the system
+// roots when set, CertFile and KeyFile present a client certificate for
+// mutual TLS, and ServerName overrides the name verified on the server's
+// certificate.
+type Files struct {
+	CAFile string
+	CertFile string
+	KeyFile string
+	ServerName string
+}
+
+// Reloader holds the TLS config built from Files, rebuilding it whenever one
+// of the files changes on disk. The check is made each time Config is called,
+// which is once per connection attempt.
+type Reloader struct {
+	files Files
+	mu sync.Mutex
+	config *tls.Config
+	stamps []fileStamp
+}
+
+// fileStamp identifies a version of a file. Secrets mounted in Kubernetes are
+// rotated by swapping a symlink, which os.Stat follows.
+type fileStamp struct {
+	modTime time.Time
+	size int64
+}
+
+// NewReloader loads the files, failing if they don't make a valid TLS config.
+func NewReloader(files Files) (*Reloader, error) {
+	if (files.CertFile == "") != (files.KeyFile == "") {
+		return nil, errors.New("a client certificate needs both a cert file and a key file")
+	}
+	r := &Reloader{files: files}
+	stamps, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	r.config, err = r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.stamps = stamps
+	return r, nil
+}
+
+// Config returns the current TLS config, reloading it first if the files have
+// changed. A failed reload, such as a half written rotation, keeps the
+// previous config and is tried again next time.
+func (r *Reloader) Config() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stamps, err := r.stat()
+	if err != nil {
+		log.Printf("error checking tls files, keeping current config: %s", err)
+		return r.config
+	}
+	if stampsEqual(stamps, r.stamps) {
+		return r.config
+	}
+	config, err := r.load()
+	if err != nil {
+		log.Printf("error reloading tls files, keeping current config: %s", err)
+		return r.config
+	}
+	log.Println("reloaded tls files")
+	r.config = config
+	r.stamps = stamps
+	return config
+}
+
+func (r *Reloader) paths() []string {
+	var paths []string
+	for _, path := range []string{r.files.CAFile, r.files.CertFile, r.files.KeyFile} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func (r *Reloader) stat() ([]fileStamp, error) {
+	paths := r.paths()
+	stamps := make([]fileStamp, len(paths))
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		stamps[i] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps, nil
+}
+
+func (r *Reloader) load() (*tls.Config, error) {
+	config := &tls.Config{ServerName: r.files.ServerName, MinVersion: tls.VersionTLS12}
+	if r.files.CAFile != "" {
+		pem, err := ioutil.ReadFile(r.files.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file: %s", r.files.CAFile)
+		}
+	}
+	if r.files.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(r.files.CertFile, r.files.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func stampsEqual(a, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
+
+// NewCredentials returns gRPC transport credentials that handshake with the
+// reloader's current config, so that each new connection uses the latest
+// certificates. Established connections keep the ones they were made with.
+func NewCredentials(reloader *Reloader) credentials.TransportCredentials {
+	return &reloadingCredentials{reloader: reloader}
+}
+
+type reloadingCredentials struct {
+	reloader *Reloader
+	serverName string
+}
+
+func (c *reloadingCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	config := c.reloader.Config()
+	if c.serverName != "" {
+		config = config.Clone()
+		config.ServerName = c.serverName
+	}
+	return credentials.NewTLS(config).ClientHandshake(ctx, authority, conn)
+}
+
+func (c *reloadingCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("tlsutil credentials are for clients only")
+}
+
+func (c *reloadingCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "tls", SecurityVersion: "1.2", ServerName: c.serverName}
+}
+
+func (c *reloadingCredentials) Clone() credentials.TransportCredentials {
+	clone := *c
+	return &clone
+}
+
+func (c *reloadingCredentials) OverrideServerName(name string) error {
+	c.serverName = name
+	return nil
+}
@@ -0,0 +1,235 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/flight"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for tests.
+type testCA struct {
+	cert *x509.Certificate
+	key *ecdsa.PrivateKey
+	pem []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{CommonName: name},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		IsCA: true,
+		KeyUsage: x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM encoded certificate and key for name.
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{CommonName: name},
+		DNSNames: []string{name},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		KeyUsage: x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile writes data to path and moves its modification time on by age,
+// so that a rewrite within the file system's timestamp resolution is noticed.
+func writeFile(t *testing.T, path string, data []byte, age time.Duration) string {
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	stamp := time.Now().Add(age)
+	if err := os.Chtimes(path, stamp, stamp); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// startTLSFlightServer serves GetSchema over TLS as model.internal. Client
+// certificates signed by clientCA are required if it is set.
+func startTLSFlightServer(t *testing.T, ca *testCA, clientCA *testCA) string {
+	certPEM, keyPEM := ca.issue(t, "model.internal", x509.ExtKeyUsageServerAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCA != nil {
+		config.ClientCAs = x509.NewCertPool()
+		config.ClientCAs.AddCert(clientCA.cert)
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	server := flight.NewServerWithMiddleware(nil, nil, grpc.Creds(credentials.NewTLS(config)))
+	server.RegisterFlightService(&flight.FlightServiceService{
+		GetSchema: func(ctx context.Context, d *flight.FlightDescriptor) (*flight.SchemaResult, error) {
+			schema := arrow.NewSchema([]arrow.Field{{Name: "score", Type: arrow.PrimitiveTypes.Float64}}, nil)
+			return &flight.SchemaResult{Schema: flight.SerializeSchema(schema, memory.DefaultAllocator)}, nil
+		},
+	})
+	if err := server.Init("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	t.Cleanup(server.Shutdown)
+	return server.Addr().String()
+}
+
+// call makes one request over a new connection to addr.
+func call(t *testing.T, addr string, reloader *Reloader) error {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(NewCredentials(reloader)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = flight.NewFlightServiceClient(conn).GetSchema(ctx, &flight.FlightDescriptor{Type: flight.FlightDescriptor_PATH})
+	return err
+}
+
+func newTestReloader(t *testing.T, files Files) *Reloader {
+	reloader, err := NewReloader(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reloader
+}
+
+func TestCredentials_server_name_override(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	addr := startTLSFlightServer(t, ca, nil)
+	caFile := writeFile(t, filepath.Join(t.TempDir(), "ca.pem"), ca.pem, 0)
+
+	err := call(t, addr, newTestReloader(t, Files{CAFile: caFile, ServerName: "model.internal"}))
+	assert.Nil(t, err)
+	err = call(t, addr, newTestReloader(t, Files{CAFile: caFile}))
+	assert.Error(t, err, "the certificate is for model.internal, not the address dialled")
+}
+
+func TestCredentials_unknown_ca(t *testing.T) {
+	addr := startTLSFlightServer(t, newTestCA(t, "ca"), nil)
+	caFile := writeFile(t, filepath.Join(t.TempDir(), "ca.pem"), newTestCA(t, "other").pem, 0)
+
+	err := call(t, addr, newTestReloader(t, Files{CAFile: caFile, ServerName: "model.internal"}))
+	assert.Error(t, err)
+}
+
+func TestCredentials_mutual_tls(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	addr := startTLSFlightServer(t, ca, ca)
+	dir := t.TempDir()
+	caFile := writeFile(t, filepath.Join(dir, "ca.pem"), ca.pem, 0)
+	certPEM, keyPEM := ca.issue(t, "decisioner", x509.ExtKeyUsageClientAuth)
+	certFile := writeFile(t, filepath.Join(dir, "client.pem"), certPEM, 0)
+	keyFile := writeFile(t, filepath.Join(dir, "client-key.pem"), keyPEM, 0)
+
+	err := call(t, addr, newTestReloader(t, Files{CAFile: caFile, ServerName: "model.internal"}))
+	assert.Error(t, err, "the server should require a client certificate")
+	err = call(t, addr, newTestReloader(t, Files{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "model.internal"}))
+	assert.Nil(t, err)
+}
+
+func TestReloader_rotation(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	addr := startTLSFlightServer(t, ca, ca)
+	dir := t.TempDir()
+	caFile := writeFile(t, filepath.Join(dir, "ca.pem"), ca.pem, 0)
+	// start with a certificate the server won't accept.
+	certPEM, keyPEM := newTestCA(t, "rogue").issue(t, "decisioner", x509.ExtKeyUsageClientAuth)
+	certFile := writeFile(t, filepath.Join(dir, "client.pem"), certPEM, 0)
+	keyFile := writeFile(t, filepath.Join(dir, "client-key.pem"), keyPEM, 0)
+	reloader := newTestReloader(t, Files{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "model.internal"})
+	assert.Error(t, call(t, addr, reloader))
+
+	certPEM, keyPEM = ca.issue(t, "decisioner", x509.ExtKeyUsageClientAuth)
+	writeFile(t, certFile, certPEM, time.Minute)
+	writeFile(t, keyFile, keyPEM, time.Minute)
+	assert.Nil(t, call(t, addr, reloader), "new connections should use the rotated certificate")
+}
+
+func TestReloader_keeps_config_on_bad_reload(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, "decisioner", x509.ExtKeyUsageClientAuth)
+	certFile := writeFile(t, filepath.Join(dir, "client.pem"), certPEM, 0)
+	keyFile := writeFile(t, filepath.Join(dir, "client-key.pem"), keyPEM, 0)
+	reloader := newTestReloader(t, Files{CertFile: certFile, KeyFile: keyFile})
+	before := reloader.Config()
+
+	writeFile(t, certFile, []byte("half written"), time.Minute)
+	assert.Same(t, before, reloader.Config(), "a bad rotation should keep the current config")
+
+	writeFile(t, certFile, certPEM, 2*time.Minute)
+	assert.NotSame(t, before, reloader.Config(), "a fixed rotation should be picked up")
+}
+
+func TestNewReloader_errors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := writeFile(t, filepath.Join(dir, "ca.pem"), []byte("not a certificate"), 0)
+	tests := []struct {
+		name string
+		files Files
+	}{
+		{"missing ca", Files{CAFile: filepath.Join(dir, "missing.pem")}},
+		{"invalid ca", Files{CAFile: notPEM}},
+		{"cert without key", Files{CertFile: notPEM}},
+		{"invalid cert", Files{CertFile: notPEM, KeyFile: notPEM}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReloader(tt.files)
+			assert.Error(t, err)
+		})
+	}
+}
+
@@ -2,117 +2,292 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/ReneKroon/ttlcache/v2"
-	"github.com/apache/arrow/go/v7/arrow/flight"
-	"github.com/apache/arrow/go/v7/arrow/memory"
-	"github.com/aws/aws-sdk-go-v2/config"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/ehenry2/avro-flight-decisioner/internal/arrowconv"
 	"github.com/ehenry2/avro-flight-decisioner/internal/avroutil"
+	"github.com/ehenry2/avro-flight-decisioner/internal/config"
+	"github.com/ehenry2/avro-flight-decisioner/internal/health"
 	"github.com/ehenry2/avro-flight-decisioner/internal/scoring"
-	"google.golang.org/grpc"
+	"github.com/google/uuid"
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"log"
+	"net/http"
 	"time"
 )
 
 const (
-	codecLoaderKey = "codecLoader"
-	scorerKey = "flightScorer"
+	// correlationExtension carries the id of the event that produced a decision.
+	correlationExtension = "correlationid"
+	// fallbackExtension is set on responses scored by the fallback instead of the model.
+	fallbackExtension = "fallback"
+	// modelExtension names the model an event was routed to.
+	modelExtension = "model"
+	// variantExtension names the variant of a traffic split that scored an event.
+	variantExtension = "variant"
 )
 
+// ResponseConfig controls the CloudEvent emitted for each scored event. The
+// output avro schema is resolved through the codec loader using EventType.
+type ResponseConfig struct {
+	EventType string
+	Source string
+}
+
+// StageTimeouts bounds how long each stage of handling an event may take. A
+// zero timeout leaves the stage bounded only by the request itself.
+type StageTimeouts struct {
+	// LoadSchema covers fetching the input and output avro schemas.
+	LoadSchema time.Duration
+	Score time.Duration
+}
+
+// Decisioner turns each avro encoded CloudEvent into a scored decision event.
+type Decisioner struct {
+	loader avroutil.AvroCodecLoader
+	scorer scoring.ModelScorer
+	response ResponseConfig
+	timeouts StageTimeouts
+}
+
+// NewDecisioner checks its dependencies up front, so a misconfigured service
+// fails at startup rather than on its first event.
+func NewDecisioner(loader avroutil.AvroCodecLoader, scorer scoring.ModelScorer,
+	response ResponseConfig, timeouts StageTimeouts) (*Decisioner, error) {
+	if loader == nil {
+		return nil, errors.New("a codec loader is required")
+	}
+	if scorer == nil {
+		return nil, errors.New("a model scorer is required")
+	}
+	if response.EventType == "" || response.Source == "" {
+		return nil, errors.New("a response event type and source are required")
+	}
+	return &Decisioner{
+		loader: loader,
+		scorer: scorer,
+		response: response,
+		timeouts: timeouts,
+	}, nil
+}
 
-func HandleMessage(ctx context.Context, event cloudevents.Event) {
+// Handle is the CloudEvents receiver.
+func (d *Decisioner) Handle(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, cloudevents.Result) {
 	log.Println("received message")
 	start := time.Now()
-	// pull the codec loader out of the context and load the avro codec.
-	codecLoader := ctx.Value(codecLoaderKey)
-	if codecLoader == nil {
-		log.Fatalln("required codec loader not in context..exiting")
-	}
-	loader, ok := codecLoader.(avroutil.AvroCodecLoader)
-	if !ok {
-		log.Fatalln("codec loader in context is not a valid AvroCodecLoader")
+	// load the avro codec for the event.
+	loadCtx, cancel := withStageTimeout(ctx, d.timeouts.LoadSchema)
+	defer cancel()
+	codec, schema, err := d.loader.LoadSchema(loadCtx, event.Type())
+	if isContextError(err) {
+		return nil, newResult(http.StatusServiceUnavailable, "schema did not load: event: %s, reason: %w", event.Type(), err)
 	}
-	codec, err := loader.LoadCodec(event.Type())
 	if err != nil {
-		log.Fatalf("error creating avro codec: %s", err)
+		return nil, internalError("error creating avro codec: event: %s, reason: %w", event.Type(), err)
 	}
 
 	// convert from avro to generic map
 	datum, _, err := codec.NativeFromBinary(event.Data())
 	if err != nil {
-		log.Fatalf("error decoding from binary: %s", err)
+		return nil, badRequest("error decoding from binary: event: %s, reason: %w", event.ID(), err)
 	}
 	data, ok := datum.(map[string]interface{})
 	if !ok {
-		log.Fatalln("could not convert datum to map")
+		return nil, badRequest("could not convert datum to map: event: %s", event.ID())
 	}
 
-	// pull out the flight client
-	flightScorer := ctx.Value(scorerKey)
-	scorer, ok := flightScorer.(*scoring.FlightModelScorer)
-	if !ok {
-		log.Println("could not cast to flight client")
+	// run the scoring
+	scoreCtx, cancel := withStageTimeout(ctx, d.timeouts.Score)
+	defer cancel()
+	scoreCtx = scoring.WithEventID(scoring.WithEventType(scoreCtx, event.Type()), event.ID())
+	scoreCtx = scoring.WithAttributes(scoreCtx, eventAttributes(event))
+	scoreCtx, decision := scoring.WithDecision(scoreCtx)
+	scores, err := d.scorer.ScoreModel(scoreCtx, schema, data)
+	if err != nil {
+		return nil, scoringError(err)
 	}
 
-	// run the scoring
-	_, err = scorer.ScoreModel(data)
+	// encode the scores with the output schema and build the response.
+	outCtx, cancel := withStageTimeout(ctx, d.timeouts.LoadSchema)
+	defer cancel()
+	outCodec, outSchema, err := d.loader.LoadSchema(outCtx, d.response.EventType)
+	if isContextError(err) {
+		return nil, newResult(http.StatusServiceUnavailable, "output schema did not load: event: %s, reason: %w", d.response.EventType, err)
+	}
+	if err != nil {
+		return nil, internalError("error creating output avro codec: event: %s, reason: %w", d.response.EventType, err)
+	}
+	// scores of nullable fields may come back bare, goavro wants them tagged
+	// with their union branch.
+	scores = arrowconv.WrapUnions(outSchema, scores)
+	response, err := newResponseEvent(d.response, event, outCodec, scores, decision)
 	if err != nil {
-		log.Fatalf("error scoring: %s", err)
+		return nil, internalError("error creating response event: %w", err)
 	}
 
 	elapsed := time.Since(start)
 	log.Printf("took %d milliseconds", elapsed.Milliseconds())
-	// convert back to map
 	log.Println("done")
+	return response, cloudevents.ResultACK
 }
 
-func initSchemaLoader() avroutil.AvroCodecLoader {
-	bucket := "dqhub-test"
-	prefix := "not-a-prefix"
-	cfg, err := config.LoadDefaultConfig(context.Background())
-	if err != nil {
-		log.Fatalf("unable to load SDK config, %v", err)
+func withStageTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
-	client := s3.NewFromConfig(cfg)
-	cache := ttlcache.NewCache()
-	err = cache.SetTTL(10 * time.Minute)
-	if err != nil {
-		log.Fatalln(err)
+	return context.WithTimeout(ctx, timeout)
+}
+
+// badRequest NACKs an event that can never be processed, so the broker
+// dead-letters it rather than retrying.
+func badRequest(format string, args ...interface{}) cloudevents.Result {
+	return newResult(http.StatusBadRequest, format, args...)
+}
+
+// internalError NACKs an event that failed for reasons unrelated to its payload.
+func internalError(format string, args ...interface{}) cloudevents.Result {
+	return newResult(http.StatusInternalServerError, format, args...)
+}
+
+// scoringError maps a failure from the model scorer onto a status code. The
+// model server being unavailable or overloaded is reported as 503 so the
+// broker backs off and retries, any other flight failure is a 502.
+func scoringError(err error) cloudevents.Result {
+	if errors.Is(err, scoring.ErrConversion) || errors.Is(err, scoring.ErrNoRoute) {
+		return internalError("error scoring: %w", err)
+	}
+	if isContextError(err) {
+		return newResult(http.StatusServiceUnavailable, "scoring did not complete: %w", err)
 	}
-	return avroutil.NewS3AvroCodecLoader(cache, client, bucket, prefix)
+	if errors.Is(err, scoring.ErrCircuitOpen) {
+		return newResult(http.StatusServiceUnavailable, "model server unavailable: %w", err)
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return newResult(http.StatusServiceUnavailable, "model server unavailable: %w", err)
+	default:
+		return newResult(http.StatusBadGateway, "error scoring: %w", err)
+	}
+}
+
+// isContextError reports whether err came from a stage running out of time or
+// the request being cancelled, which are worth retrying.
+func isContextError(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// eventAttributes returns the event's string valued attributes and its
+// extensions, for scorers that split traffic on them.
+func eventAttributes(event cloudevents.Event) map[string]string {
+	attributes := map[string]string{
+		"id": event.ID(),
+		"source": event.Source(),
+		"type": event.Type(),
+		"subject": event.Subject(),
+	}
+	for name, value := range event.Extensions() {
+		attributes[name] = fmt.Sprint(value)
+	}
+	return attributes
 }
 
-func getFlightClient() (flight.FlightServiceClient, error) {
-	conn, err := grpc.Dial("127.0.0.1:9998", grpc.WithInsecure(), grpc.WithBlock())
+func newResult(statusCode int, format string, args ...interface{}) cloudevents.Result {
+	result := cloudevents.NewHTTPResult(statusCode, format, args...)
+	log.Println(result)
+	return result
+}
+
+func newResponseEvent(cfg ResponseConfig, request cloudevents.Event, codec *goavro.Codec,
+	scores map[string]interface{}, decision *scoring.Decision) (*cloudevents.Event, error) {
+	data, err := codec.BinaryFromNative(nil, scores)
+	if err != nil {
+		return nil, err
+	}
+	response := cloudevents.NewEvent()
+	response.SetID(uuid.New().String())
+	response.SetType(cfg.EventType)
+	response.SetSource(cfg.Source)
+	response.SetExtension(correlationExtension, request.ID())
+	if decision.Model != "" {
+		response.SetExtension(modelExtension, decision.Model)
+	}
+	if decision.Variant != "" {
+		response.SetExtension(variantExtension, decision.Variant)
+	}
+	if decision.Fallback {
+		response.SetExtension(fallbackExtension, true)
+	}
+	err = response.SetData("application/octet-stream", data)
 	if err != nil {
 		return nil, err
 	}
-	return flight.NewFlightServiceClient(conn), nil
+	return &response, nil
 }
 
-func getScorer() *scoring.FlightModelScorer {
-	flightClient, err := getFlightClient()
+func initSchemaLoader(cfg config.SchemaConfig) avroutil.AvroCodecLoader {
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("unable to load SDK config, %v", err)
+	}
+	client := s3.NewFromConfig(awsCfg)
+	cache := ttlcache.NewCache()
+	err = cache.SetTTL(cfg.CacheTTL)
 	if err != nil {
-		log.Fatalf("failed to instantiate flight client: %s", err)
+		log.Fatalln(err)
 	}
-	conv := arrowconv.NewArrowConverter(memory.NewGoAllocator())
-	return scoring.NewFlightModelScorer(flightClient, conv)
+	return avroutil.NewS3AvroCodecLoader(cache, client, cfg.Bucket, cfg.Prefix)
+}
+
+// serveHealth serves liveness and readiness on their own port. The process is
+// live as long as it answers, and ready once the schema store and every model
+// connection are, and no model's circuit breaker is open. With a fallback the
+// models are reported but only the schema store decides readiness.
+func serveHealth(port int, checks []health.Check) {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", health.Handler(nil))
+	mux.Handle("/readyz", health.Handler(checks))
+	log.Printf("serving health checks: port: %d", port)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), mux))
 }
 
 func main() {
-	scorer := getScorer()
-	loader := initSchemaLoader()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("error loading config: %s", err)
+	}
+	scorer, checks, err := getScorer(cfg)
+	if err != nil {
+		log.Fatalf("error creating scorer: %s", err)
+	}
+	loader := initSchemaLoader(cfg.Schema)
+	probeEventType := cfg.Health.ProbeEventType
+	if probeEventType == "" {
+		probeEventType = cfg.Response.EventType
+	}
+	checks = append(checks, health.CodecCheck("schema:"+probeEventType, loader, probeEventType))
+	go serveHealth(cfg.Health.Port, checks)
 	log.Println("starting cloud events client")
-	c, err := cloudevents.NewClientHTTP()
+	c, err := cloudevents.NewClientHTTP(cloudevents.WithPort(cfg.Port))
 	if err != nil {
 		log.Fatalf("error starting cloudwatch client: %s", err)
 	}
+	decisioner, err := NewDecisioner(loader, scorer, ResponseConfig{
+		EventType: cfg.Response.EventType,
+		Source: cfg.Response.Source,
+	}, StageTimeouts{
+		LoadSchema: cfg.Timeouts.LoadSchema,
+		Score: cfg.Timeouts.Score,
+	})
+	if err != nil {
+		log.Fatalf("error creating decisioner: %s", err)
+	}
 	log.Println("starting receiver")
-	// initialize s3 client here and add to context
-	ctx := context.WithValue(context.Background(), codecLoaderKey, loader)
-	ctx = context.WithValue(ctx, scorerKey, scorer)
 	log.Fatal(
-		c.StartReceiver(ctx, HandleMessage))
+		c.StartReceiver(context.Background(), decisioner.Handle))
 }
@@ -2,46 +2,401 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/ReneKroon/ttlcache/v2"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/ehenry2/avro-flight-decisioner/internal/arrowconv"
+	"github.com/ehenry2/avro-flight-decisioner/internal/avroutil"
+	"github.com/ehenry2/avro-flight-decisioner/internal/scoring"
 	"github.com/ehenry2/avro-flight-decisioner/mocks"
 	"github.com/golang/mock/gomock"
 	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
+const testFeatureSchema = `{"doc": "a risk model feature", "name": "ultra_risk_version_6_19", "type": "record", "fields": [{"name": "app_id", "type": "string"}, {"name": "bank_balance_30_days", "type": "double"}, {"name": "credit_score", "type": "int"}]}`
 
-func Test_handleMessage(t *testing.T) {
-	// test configuration
-	avroSchema := `{"doc": "a risk model feature", "name": "ultra_risk_version_6_19", "type": "record", "fields": [{"name": "app_id", "type": "string"}, {"name": "bank_balance_30_days", "type": "double"}, {"name": "credit_score", "type": "int"}]}`
-	eventType := "custom.fake-event"
-	codec, _ := goavro.NewCodec(avroSchema)
+func getTestSchema(t *testing.T, avroSchema string) (*goavro.Codec, *arrow.Schema) {
+	codec, err := goavro.NewCodec(avroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arrowSchema, err := arrowconv.SchemaFromAvro(avroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codec, arrowSchema
+}
+
+func getTestEvent(t *testing.T, eventType string, data []byte) cloudevents.Event {
+	e := cloudevents.NewEvent()
+	e.SetID("abc123")
+	e.SetSource("upstream")
+	e.SetType(eventType)
+	err := e.SetData("application/octet-stream", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func getTestFeatures(t *testing.T, codec *goavro.Codec) []byte {
 	record := make(map[string]interface{})
 	record["app_id"] = "1000"
 	record["bank_balance_30_days"] = 50000.00
 	record["credit_score"] = 800
-	data, _ := codec.BinaryFromNative(nil, record)
+	data, err := codec.BinaryFromNative(nil, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+var testResponseConfig = ResponseConfig{
+	EventType: "custom.fake-decision",
+	Source: "decisioner-test",
+}
+
+func newTestDecisioner(t *testing.T, loader avroutil.AvroCodecLoader, scorer scoring.ModelScorer,
+	response ResponseConfig, timeouts StageTimeouts) *Decisioner {
+	d, err := NewDecisioner(loader, scorer, response, timeouts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func resultStatusCode(t *testing.T, result cloudevents.Result) int {
+	var httpResult *cehttp.Result
+	if !errors.As(result, &httpResult) {
+		t.Fatalf("result is not an http result: %s", result)
+	}
+	return httpResult.StatusCode
+}
+
+
+func TestDecisioner_Handle(t *testing.T) {
+	// test configuration
+	outputSchema := `{"doc": "a risk model decision", "name": "ultra_risk_decision", "type": "record", "fields": [{"name": "app_id", "type": "string"}, {"name": "score", "type": "double"}]}`
+	eventType := "custom.fake-event"
+	responseCfg := ResponseConfig{
+		EventType: "custom.fake-decision",
+		Source: "decisioner-test",
+	}
+	codec, schema := getTestSchema(t, testFeatureSchema)
+	outCodec, outSchema := getTestSchema(t, outputSchema)
+	scores := make(map[string]interface{})
+	scores["app_id"] = "1000"
+	scores["score"] = 0.25
 
 	// set up mocks.
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	m := mocks.NewMockAvroCodecLoader(ctrl)
 	m.EXPECT().
-		LoadCodec(gomock.Eq(eventType)).
-		Return(codec, nil)
-
+		LoadSchema(gomock.Any(), gomock.Eq(eventType)).
+		Return(codec, schema, nil)
+	m.EXPECT().
+		LoadSchema(gomock.Any(), gomock.Eq(responseCfg.EventType)).
+		Return(outCodec, outSchema, nil)
+	scorer := mocks.NewMockModelScorer(ctrl)
+	scorer.EXPECT().
+		ScoreModel(gomock.Any(), gomock.Eq(schema), gomock.Any()).
+		Return(scores, nil)
 
 	// create the cloud event
-	e := cloudevents.NewEvent()
-	e.SetID("abc123")
-	e.SetSource("upstream")
-	e.SetType(eventType)
-	_ = e.SetData("application/octet-stream", data)
+	e := getTestEvent(t, eventType, getTestFeatures(t, codec))
 
-	// create the context
-	ctx := context.WithValue(context.Background(), codecLoaderKey, m)
+	// create the decisioner
+	d := newTestDecisioner(t, m, scorer, responseCfg, StageTimeouts{})
 
 	// run the test
-	HandleMessage(ctx, e)
-	t.Fail()
+	response, result := d.Handle(context.Background(), e)
+
+	// verify the response event.
+	assert.True(t, cloudevents.IsACK(result), "a scored event should be acknowledged")
+	if !assert.NotNil(t, response, "a response event should be returned") {
+		return
+	}
+	assert.Equal(t, responseCfg.EventType, response.Type(), "response should have the configured type")
+	assert.Equal(t, responseCfg.Source, response.Source(), "response should have the configured source")
+	assert.Equal(t, "abc123", response.Extensions()[correlationExtension],
+		"response should be correlated with the original event")
+	assert.NotEqual(t, e.ID(), response.ID(), "response should have its own id")
+	assert.NotContains(t, response.Extensions(), fallbackExtension, "a model decision is not a fallback")
+	assert.NotContains(t, response.Extensions(), modelExtension, "an unrouted decision names no model")
+	assert.NotContains(t, response.Extensions(), variantExtension, "an unsplit decision names no variant")
+	decoded, _, err := outCodec.NativeFromBinary(response.Data())
+	assert.Nil(t, err, "response data should decode with the output schema")
+	assert.Equal(t, scores, decoded, "response data should contain the scores")
+}
+
+func TestDecisioner_Handle_nullable_scores(t *testing.T) {
+	outputSchema := `{"name": "ultra_risk_decision", "type": "record", "fields": [{"name": "score", "type": ["null", "double"]}, {"name": "reason", "type": ["null", "string"]}]}`
+	eventType := "custom.fake-event"
+	codec, schema := getTestSchema(t, testFeatureSchema)
+	outCodec, outSchema := getTestSchema(t, outputSchema)
+
+	// set up mocks, the scorer answers with bare values for the nullable fields.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockAvroCodecLoader(ctrl)
+	m.EXPECT().
+		LoadSchema(gomock.Any(), gomock.Eq(eventType)).
+		Return(codec, schema, nil)
+	m.EXPECT().
+		LoadSchema(gomock.Any(), gomock.Eq(testResponseConfig.EventType)).
+		Return(outCodec, outSchema, nil)
+	scorer := mocks.NewMockModelScorer(ctrl)
+	scorer.EXPECT().
+		ScoreModel(gomock.Any(), gomock.Eq(schema), gomock.Any()).
+		Return(map[string]interface{}{"score": 0.25, "reason": nil}, nil)
+
+	// run the test.
+	d := newTestDecisioner(t, m, scorer, testResponseConfig, StageTimeouts{})
+	response, result := d.Handle(context.Background(), getTestEvent(t, eventType, getTestFeatures(t, codec)))
+	assert.True(t, cloudevents.IsACK(result), "nullable scores should be encoded: %s", result)
+	if !assert.NotNil(t, response, "a response event should be returned") {
+		return
+	}
+	decoded, _, err := outCodec.NativeFromBinary(response.Data())
+	assert.Nil(t, err, "response data should decode with the output schema")
+	assert.Equal(t, map[string]interface{}{
+		"score": map[string]interface{}{"double": 0.25},
+		"reason": nil,
+	}, decoded, "response data should contain the scores")
+}
+
+func TestDecisioner_Handle_decode_error(t *testing.T) {
+	eventType := "custom.fake-event"
+	codec, schema := getTestSchema(t, testFeatureSchema)
+
+	// set up mocks.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockAvroCodecLoader(ctrl)
+	m.EXPECT().
+		LoadSchema(gomock.Any(), gomock.Eq(eventType)).
+		Return(codec, schema, nil)
+	scorer := mocks.NewMockModelScorer(ctrl)
+
+	// create the decisioner
+	d := newTestDecisioner(t, m, scorer, testResponseConfig, StageTimeouts{})
+
+	// run the test with a payload that is not valid avro.
+	response, result := d.Handle(context.Background(), getTestEvent(t, eventType, []byte{1}))
+	assert.Nil(t, response, "there should be no response for an undecodable event")
+	assert.False(t, cloudevents.IsACK(result), "an undecodable event should not be acknowledged")
+	assert.Equal(t, http.StatusBadRequest, resultStatusCode(t, result), "an undecodable event is a bad request")
+}
+
+func TestDecisioner_Handle_scoring_errors(t *testing.T) {
+	eventType := "custom.fake-event"
+	codec, schema := getTestSchema(t, testFeatureSchema)
+	tests := []struct {
+		name string
+		err error
+		statusCode int
+	}{
+		{"unavailable", status.Error(codes.Unavailable, "connection refused"), http.StatusServiceUnavailable},
+		{"exhausted", status.Error(codes.ResourceExhausted, "too many requests"), http.StatusServiceUnavailable},
+		{"internal", status.Error(codes.Internal, "model blew up"), http.StatusBadGateway},
+		{"unknown", errors.New("stream closed"), http.StatusBadGateway},
+		{"circuit open", scoring.ErrCircuitOpen, http.StatusServiceUnavailable},
+		{"no route", fmt.Errorf("%w: urm_7", scoring.ErrNoRoute), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// set up mocks.
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := mocks.NewMockAvroCodecLoader(ctrl)
+			m.EXPECT().
+				LoadSchema(gomock.Any(), gomock.Eq(eventType)).
+				Return(codec, schema, nil)
+			scorer := mocks.NewMockModelScorer(ctrl)
+			scorer.EXPECT().
+				ScoreModel(gomock.Any(), gomock.Eq(schema), gomock.Any()).
+				Return(nil, tt.err)
+
+			// create the decisioner
+			d := newTestDecisioner(t, m, scorer, testResponseConfig, StageTimeouts{})
+
+			// run the test.
+			response, result := d.Handle(context.Background(), getTestEvent(t, eventType, getTestFeatures(t, codec)))
+			assert.Nil(t, response, "there should be no response when scoring fails")
+			assert.Equal(t, tt.statusCode, resultStatusCode(t, result), "status code should match the failure")
+		})
+	}
+}
+
+func TestDecisioner_Handle_scoring_timeout(t *testing.T) {
+	eventType := "custom.fake-event"
+	codec, schema := getTestSchema(t, testFeatureSchema)
+	timeouts := StageTimeouts{Score: 20 * time.Millisecond}
+
+	// set up mocks, the scorer hangs until its deadline passes.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockAvroCodecLoader(ctrl)
+	m.EXPECT().
+		LoadSchema(gomock.Any(), gomock.Eq(eventType)).
+		Return(codec, schema, nil)
+	scorer := mocks.NewMockModelScorer(ctrl)
+	scorer.EXPECT().
+		ScoreModel(gomock.Any(), gomock.Eq(schema), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ *arrow.Schema, _ map[string]interface{}) (map[string]interface{}, error) {
+			_, ok := ctx.Deadline()
+			assert.True(t, ok, "scoring should run with a deadline")
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+	// create the decisioner
+	d := newTestDecisioner(t, m, scorer, testResponseConfig, timeouts)
+
+	// run the test.
+	response, result := d.Handle(context.Background(), getTestEvent(t, eventType, getTestFeatures(t, codec)))
+	assert.Nil(t, response, "there should be no response when scoring times out")
+	assert.Equal(t, http.StatusServiceUnavailable, resultStatusCode(t, result),
+		"a scoring timeout should be retried")
+}
+
+func TestDecisioner_Handle_schema_timeout(t *testing.T) {
+	eventType := "custom.fake-event"
+	timeouts := StageTimeouts{LoadSchema: 20 * time.Millisecond}
+
+	// set up mocks, the loader hangs until its deadline passes.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockAvroCodecLoader(ctrl)
+	m.EXPECT().
+		LoadSchema(gomock.Any(), gomock.Eq(eventType)).
+		DoAndReturn(func(ctx context.Context, _ string) (*goavro.Codec, *arrow.Schema, error) {
+			<-ctx.Done()
+			return nil, nil, ctx.Err()
+		})
+
+	// create the decisioner
+	d := newTestDecisioner(t, m, mocks.NewMockModelScorer(ctrl), testResponseConfig, timeouts)
+
+	// run the test.
+	response, result := d.Handle(context.Background(), getTestEvent(t, eventType, []byte{}))
+	assert.Nil(t, response, "there should be no response when the schema times out")
+	assert.Equal(t, http.StatusServiceUnavailable, resultStatusCode(t, result),
+		"a schema timeout should be retried")
+}
+
+func TestDecisioner_Handle_fallback(t *testing.T) {
+	outputSchema := `{"name": "ultra_risk_decision", "type": "record", "fields": [{"name": "score", "type": "double"}]}`
+	eventType := "custom.fake-event"
+	responseCfg := ResponseConfig{EventType: "custom.fake-decision", Source: "decisioner-test"}
+	codec, schema := getTestSchema(t, testFeatureSchema)
+	outCodec, outSchema := getTestSchema(t, outputSchema)
+
+	// set up mocks, the scorer answers with a fallback decision.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := mocks.NewMockAvroCodecLoader(ctrl)
+	m.EXPECT().
+		LoadSchema(gomock.Any(), gomock.Eq(eventType)).
+		Return(codec, schema, nil)
+	m.EXPECT().
+		LoadSchema(gomock.Any(), gomock.Eq(responseCfg.EventType)).
+		Return(outCodec, outSchema, nil)
+	scorer := mocks.NewMockModelScorer(ctrl)
+	scorer.EXPECT().
+		ScoreModel(gomock.Any(), gomock.Eq(schema), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ *arrow.Schema, _ map[string]interface{}) (map[string]interface{}, error) {
+			assert.Equal(t, eventType, scoring.EventTypeFrom(ctx), "the event type should be passed on for routing")
+			assert.Equal(t, "abc123", scoring.EventIDFrom(ctx), "the event id should be passed on for shadow results")
+			assert.Equal(t, eventType, scoring.AttributesFrom(ctx)["type"], "the event attributes should be passed on for splitting")
+			scoring.DecisionFrom(ctx).Model = "risk"
+			scoring.DecisionFrom(ctx).Variant = "risk-v2"
+			scoring.DecisionFrom(ctx).Fallback = true
+			return map[string]interface{}{"score": 0.5}, nil
+		})
+
+	// create the decisioner
+	d := newTestDecisioner(t, m, scorer, responseCfg, StageTimeouts{})
+
+	// run the test.
+	response, result := d.Handle(context.Background(), getTestEvent(t, eventType, getTestFeatures(t, codec)))
+	assert.True(t, cloudevents.IsACK(result), "a fallback decision should be acknowledged")
+	if !assert.NotNil(t, response, "a response event should be returned") {
+		return
+	}
+	assert.Equal(t, true, response.Extensions()[fallbackExtension], "the response should be flagged as a fallback")
+	assert.Equal(t, "risk", response.Extensions()[modelExtension], "the response should name the routed model")
+	assert.Equal(t, "risk-v2", response.Extensions()[variantExtension], "the response should name the variant")
+}
+
+func TestNewDecisioner_missing_dependencies(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	loader := mocks.NewMockAvroCodecLoader(ctrl)
+	scorer := mocks.NewMockModelScorer(ctrl)
+
+	_, err := NewDecisioner(nil, scorer, testResponseConfig, StageTimeouts{})
+	assert.Error(t, err, "a codec loader should be required")
+	_, err = NewDecisioner(loader, nil, testResponseConfig, StageTimeouts{})
+	assert.Error(t, err, "a scorer should be required")
+	_, err = NewDecisioner(loader, scorer, ResponseConfig{}, StageTimeouts{})
+	assert.Error(t, err, "a response event type should be required")
+}
+
+func TestDecisioner_Handle_pipeline(t *testing.T) {
+	outputSchema := `{"name": "ultra_risk_decision", "type": "record", "fields": [{"name": "score", "type": "double"}]}`
+	eventType := "custom.fake-event"
+	schemas := map[string]string{
+		"risk/" + eventType + ".json": testFeatureSchema,
+		"risk/" + testResponseConfig.EventType + ".json": outputSchema,
+	}
+	codec, _ := getTestSchema(t, testFeatureSchema)
+
+	// set up mocks, the schemas come from a fake s3 and go through the real loader.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s3Client := mocks.NewMockS3Client(ctrl)
+	s3Client.EXPECT().
+		GetObject(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+			return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(schemas[*input.Key]))}, nil
+		}).
+		Times(2)
+	loader := avroutil.NewS3AvroCodecLoader(ttlcache.NewCache(), s3Client, "schemas", "risk")
+	scorer := mocks.NewMockModelScorer(ctrl)
+	scorer.EXPECT().
+		ScoreModel(gomock.Any(), gomock.Any(), gomock.Eq(map[string]interface{}{
+			"app_id": "1000",
+			"bank_balance_30_days": 50000.00,
+			"credit_score": int32(800),
+		})).
+		DoAndReturn(func(_ context.Context, schema *arrow.Schema, _ map[string]interface{}) (map[string]interface{}, error) {
+			assert.Equal(t, "credit_score", schema.Field(2).Name, "the scorer should get the arrow schema of the event")
+			return map[string]interface{}{"score": 0.25}, nil
+		})
 
+	// run the test.
+	d := newTestDecisioner(t, loader, scorer, testResponseConfig, StageTimeouts{})
+	response, result := d.Handle(context.Background(), getTestEvent(t, eventType, getTestFeatures(t, codec)))
+	assert.True(t, cloudevents.IsACK(result), "a scored event should be acknowledged")
+	if !assert.NotNil(t, response, "a response event should be returned") {
+		return
+	}
+	outCodec, _ := goavro.NewCodec(outputSchema)
+	decoded, _, err := outCodec.NativeFromBinary(response.Data())
+	assert.Nil(t, err, "response data should decode with the output schema")
+	assert.Equal(t, map[string]interface{}{"score": 0.25}, decoded, "response data should contain the scores")
 }
@@ -8,9 +8,10 @@ import (
 	context "context"
 	reflect "reflect"
 
+	arrow "github.com/apache/arrow/go/v7/arrow"
 	s3 "github.com/aws/aws-sdk-go-v2/service/s3"
 	gomock "github.com/golang/mock/gomock"
-	v2 "github.com/linkedin/goavro/v2"
+	goavro "github.com/linkedin/goavro/v2"
 )
 
 // MockS3Client is a mock of S3Client interface.
@@ -80,16 +81,32 @@ func (m *MockAvroCodecLoader) EXPECT() *MockAvroCodecLoaderMockRecorder {
 }
 
 // LoadCodec mocks base method.
-func (m *MockAvroCodecLoader) LoadCodec(arg0 string) (*v2.Codec, error) {
+func (m *MockAvroCodecLoader) LoadCodec(arg0 context.Context, arg1 string) (*goavro.Codec, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LoadCodec", arg0)
-	ret0, _ := ret[0].(*v2.Codec)
+	ret := m.ctrl.Call(m, "LoadCodec", arg0, arg1)
+	ret0, _ := ret[0].(*goavro.Codec)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // LoadCodec indicates an expected call of LoadCodec.
-func (mr *MockAvroCodecLoaderMockRecorder) LoadCodec(arg0 interface{}) *gomock.Call {
+func (mr *MockAvroCodecLoaderMockRecorder) LoadCodec(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadCodec", reflect.TypeOf((*MockAvroCodecLoader)(nil).LoadCodec), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadCodec", reflect.TypeOf((*MockAvroCodecLoader)(nil).LoadCodec), arg0, arg1)
+}
+
+// LoadSchema mocks base method.
+func (m *MockAvroCodecLoader) LoadSchema(arg0 context.Context, arg1 string) (*goavro.Codec, *arrow.Schema, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadSchema", arg0, arg1)
+	ret0, _ := ret[0].(*goavro.Codec)
+	ret1, _ := ret[1].(*arrow.Schema)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoadSchema indicates an expected call of LoadSchema.
+func (mr *MockAvroCodecLoaderMockRecorder) LoadSchema(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadSchema", reflect.TypeOf((*MockAvroCodecLoader)(nil).LoadSchema), arg0, arg1)
 }
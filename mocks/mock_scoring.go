@@ -0,0 +1,294 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/scoring/scorer.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	arrow "github.com/apache/arrow/go/v7/arrow"
+	flight "github.com/apache/arrow/go/v7/arrow/flight"
+	gomock "github.com/golang/mock/gomock"
+	grpc "google.golang.org/grpc"
+)
+
+// MockModelScorer is a mock of ModelScorer interface.
+type MockModelScorer struct {
+	ctrl     *gomock.Controller
+	recorder *MockModelScorerMockRecorder
+}
+
+// MockModelScorerMockRecorder is the mock recorder for MockModelScorer.
+type MockModelScorerMockRecorder struct {
+	mock *MockModelScorer
+}
+
+// NewMockModelScorer creates a new mock instance.
+func NewMockModelScorer(ctrl *gomock.Controller) *MockModelScorer {
+	mock := &MockModelScorer{ctrl: ctrl}
+	mock.recorder = &MockModelScorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockModelScorer) EXPECT() *MockModelScorerMockRecorder {
+	return m.recorder
+}
+
+// ScoreModel mocks base method.
+func (m *MockModelScorer) ScoreModel(arg0 context.Context, arg1 *arrow.Schema, arg2 map[string]interface{}) (map[string]interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScoreModel", arg0, arg1, arg2)
+	ret0, _ := ret[0].(map[string]interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ScoreModel indicates an expected call of ScoreModel.
+func (mr *MockModelScorerMockRecorder) ScoreModel(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScoreModel", reflect.TypeOf((*MockModelScorer)(nil).ScoreModel), arg0, arg1, arg2)
+}
+
+// MockBatchScorer is a mock of BatchScorer interface.
+type MockBatchScorer struct {
+	ctrl     *gomock.Controller
+	recorder *MockBatchScorerMockRecorder
+}
+
+// MockBatchScorerMockRecorder is the mock recorder for MockBatchScorer.
+type MockBatchScorerMockRecorder struct {
+	mock *MockBatchScorer
+}
+
+// NewMockBatchScorer creates a new mock instance.
+func NewMockBatchScorer(ctrl *gomock.Controller) *MockBatchScorer {
+	mock := &MockBatchScorer{ctrl: ctrl}
+	mock.recorder = &MockBatchScorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBatchScorer) EXPECT() *MockBatchScorerMockRecorder {
+	return m.recorder
+}
+
+// ScoreBatch mocks base method.
+func (m *MockBatchScorer) ScoreBatch(arg0 context.Context, arg1 *arrow.Schema, arg2 []map[string]interface{}) ([]map[string]interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScoreBatch", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]map[string]interface{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ScoreBatch indicates an expected call of ScoreBatch.
+func (mr *MockBatchScorerMockRecorder) ScoreBatch(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScoreBatch", reflect.TypeOf((*MockBatchScorer)(nil).ScoreBatch), arg0, arg1, arg2)
+}
+
+// MockArrowFlightClient is a mock of ArrowFlightClient interface.
+type MockArrowFlightClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockArrowFlightClientMockRecorder
+}
+
+// MockArrowFlightClientMockRecorder is the mock recorder for MockArrowFlightClient.
+type MockArrowFlightClientMockRecorder struct {
+	mock *MockArrowFlightClient
+}
+
+// NewMockArrowFlightClient creates a new mock instance.
+func NewMockArrowFlightClient(ctrl *gomock.Controller) *MockArrowFlightClient {
+	mock := &MockArrowFlightClient{ctrl: ctrl}
+	mock.recorder = &MockArrowFlightClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockArrowFlightClient) EXPECT() *MockArrowFlightClientMockRecorder {
+	return m.recorder
+}
+
+// DoAction mocks base method.
+func (m *MockArrowFlightClient) DoAction(ctx context.Context, in *flight.Action, opts ...grpc.CallOption) (flight.FlightService_DoActionClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DoAction", varargs...)
+	ret0, _ := ret[0].(flight.FlightService_DoActionClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DoAction indicates an expected call of DoAction.
+func (mr *MockArrowFlightClientMockRecorder) DoAction(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoAction", reflect.TypeOf((*MockArrowFlightClient)(nil).DoAction), varargs...)
+}
+
+// DoExchange mocks base method.
+func (m *MockArrowFlightClient) DoExchange(ctx context.Context, opts ...grpc.CallOption) (flight.FlightService_DoExchangeClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DoExchange", varargs...)
+	ret0, _ := ret[0].(flight.FlightService_DoExchangeClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DoExchange indicates an expected call of DoExchange.
+func (mr *MockArrowFlightClientMockRecorder) DoExchange(ctx interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoExchange", reflect.TypeOf((*MockArrowFlightClient)(nil).DoExchange), varargs...)
+}
+
+// DoGet mocks base method.
+func (m *MockArrowFlightClient) DoGet(ctx context.Context, in *flight.Ticket, opts ...grpc.CallOption) (flight.FlightService_DoGetClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DoGet", varargs...)
+	ret0, _ := ret[0].(flight.FlightService_DoGetClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DoGet indicates an expected call of DoGet.
+func (mr *MockArrowFlightClientMockRecorder) DoGet(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoGet", reflect.TypeOf((*MockArrowFlightClient)(nil).DoGet), varargs...)
+}
+
+// DoPut mocks base method.
+func (m *MockArrowFlightClient) DoPut(ctx context.Context, opts ...grpc.CallOption) (flight.FlightService_DoPutClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DoPut", varargs...)
+	ret0, _ := ret[0].(flight.FlightService_DoPutClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DoPut indicates an expected call of DoPut.
+func (mr *MockArrowFlightClientMockRecorder) DoPut(ctx interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoPut", reflect.TypeOf((*MockArrowFlightClient)(nil).DoPut), varargs...)
+}
+
+// GetFlightInfo mocks base method.
+func (m *MockArrowFlightClient) GetFlightInfo(ctx context.Context, in *flight.FlightDescriptor, opts ...grpc.CallOption) (*flight.FlightInfo, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetFlightInfo", varargs...)
+	ret0, _ := ret[0].(*flight.FlightInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFlightInfo indicates an expected call of GetFlightInfo.
+func (mr *MockArrowFlightClientMockRecorder) GetFlightInfo(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFlightInfo", reflect.TypeOf((*MockArrowFlightClient)(nil).GetFlightInfo), varargs...)
+}
+
+// GetSchema mocks base method.
+func (m *MockArrowFlightClient) GetSchema(ctx context.Context, in *flight.FlightDescriptor, opts ...grpc.CallOption) (*flight.SchemaResult, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetSchema", varargs...)
+	ret0, _ := ret[0].(*flight.SchemaResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSchema indicates an expected call of GetSchema.
+func (mr *MockArrowFlightClientMockRecorder) GetSchema(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSchema", reflect.TypeOf((*MockArrowFlightClient)(nil).GetSchema), varargs...)
+}
+
+// Handshake mocks base method.
+func (m *MockArrowFlightClient) Handshake(ctx context.Context, opts ...grpc.CallOption) (flight.FlightService_HandshakeClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Handshake", varargs...)
+	ret0, _ := ret[0].(flight.FlightService_HandshakeClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Handshake indicates an expected call of Handshake.
+func (mr *MockArrowFlightClientMockRecorder) Handshake(ctx interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Handshake", reflect.TypeOf((*MockArrowFlightClient)(nil).Handshake), varargs...)
+}
+
+// ListActions mocks base method.
+func (m *MockArrowFlightClient) ListActions(ctx context.Context, in *flight.Empty, opts ...grpc.CallOption) (flight.FlightService_ListActionsClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListActions", varargs...)
+	ret0, _ := ret[0].(flight.FlightService_ListActionsClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListActions indicates an expected call of ListActions.
+func (mr *MockArrowFlightClientMockRecorder) ListActions(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListActions", reflect.TypeOf((*MockArrowFlightClient)(nil).ListActions), varargs...)
+}
+
+// ListFlights mocks base method.
+func (m *MockArrowFlightClient) ListFlights(ctx context.Context, in *flight.Criteria, opts ...grpc.CallOption) (flight.FlightService_ListFlightsClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, in}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListFlights", varargs...)
+	ret0, _ := ret[0].(flight.FlightService_ListFlightsClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFlights indicates an expected call of ListFlights.
+func (mr *MockArrowFlightClientMockRecorder) ListFlights(ctx, in interface{}, opts ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, in}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFlights", reflect.TypeOf((*MockArrowFlightClient)(nil).ListFlights), varargs...)
+}
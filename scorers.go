@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow/flight"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/ehenry2/avro-flight-decisioner/internal/arrowconv"
+	"github.com/ehenry2/avro-flight-decisioner/internal/config"
+	"github.com/ehenry2/avro-flight-decisioner/internal/flightauth"
+	"github.com/ehenry2/avro-flight-decisioner/internal/health"
+	// registers the least_request balancing policy.
+	_ "github.com/ehenry2/avro-flight-decisioner/internal/leastrequest"
+	"github.com/ehenry2/avro-flight-decisioner/internal/scoring"
+	"github.com/ehenry2/avro-flight-decisioner/internal/tlsutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	// enables client side health checking.
+	_ "google.golang.org/grpc/health"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// discoveryTimeout bounds each attempt to discover a model's schemas.
+const discoveryTimeout = 10 * time.Second
+
+// dialFlight connects to the replicas at addrs in the background, so that a
+// late model server doesn't hold up startup. A dropped connection is
+// re-established the same way, with calls failing as unavailable in the
+// meantime. Calls are balanced over the replicas that are connected and, if
+// health checking is on, serving.
+func dialFlight(addrs []string, cfg config.FlightConfig) (*grpc.ClientConn, error) {
+	creds, err := transportCredentials(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := authOptions(cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+	reconnect := backoff.DefaultConfig
+	reconnect.MaxDelay = cfg.MaxReconnectBackoff
+	opts := append([]grpc.DialOption{
+		creds,
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: reconnect, MinConnectTimeout: cfg.ConnectTimeout}),
+		// ignore service configs published in DNS.
+		grpc.WithDisableServiceConfig(),
+		grpc.WithDefaultServiceConfig(serviceConfig(cfg)),
+	}, auth...)
+	if cfg.Keepalive.Time > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time: cfg.Keepalive.Time,
+			Timeout: cfg.Keepalive.Timeout,
+			PermitWithoutStream: cfg.Keepalive.PermitWithoutStream,
+		}))
+	}
+	target := addrs[0]
+	if len(addrs) > 1 {
+		replicas := manual.NewBuilderWithScheme("replicas")
+		state := resolver.State{}
+		for _, addr := range addrs {
+			// each replica's certificate is checked against its own name.
+			state.Addresses = append(state.Addresses, resolver.Address{Addr: addr, ServerName: addr})
+		}
+		replicas.InitialState(state)
+		target = replicas.Scheme() + ":///flight"
+		opts = append(opts, grpc.WithResolvers(replicas))
+	}
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	go logStateChanges(strings.Join(addrs, ","), conn)
+	return conn, nil
+}
+
+// newFlightScorer scores with the model at path, retiring exchange streams
+// as configured so that they are spread over the connection's replicas.
+func newFlightScorer(conn *grpc.ClientConn, conv *arrowconv.ArrowConverter, path []string, cfg config.FlightConfig) *scoring.FlightModelScorer {
+	scorer := scoring.NewFlightModelScorer(flight.NewFlightServiceClient(conn), conv, path)
+	scorer.LimitStreams(scoring.StreamLimits{MaxRequests: cfg.StreamMaxRequests, MaxAge: cfg.StreamMaxAge})
+	return scorer
+}
+
+func serviceConfig(cfg config.FlightConfig) string {
+	sc := map[string]interface{}{
+		"loadBalancingConfig": []map[string]interface{}{{cfg.Balancing: map[string]interface{}{}}},
+	}
+	if cfg.HealthCheck.Enabled {
+		sc["healthCheckConfig"] = map[string]interface{}{"serviceName": cfg.HealthCheck.Service}
+	}
+	b, _ := json.Marshal(sc)
+	return string(b)
+}
+
+// logStateChanges logs each change in the connection's state until it is closed.
+func logStateChanges(addr string, conn *grpc.ClientConn) {
+	state := conn.GetState()
+	for state != connectivity.Shutdown {
+		conn.WaitForStateChange(context.Background(), state)
+		state = conn.GetState()
+		log.Printf("flight connection state changed: endpoint: %s, state: %s", addr, state)
+	}
+}
+
+// transportCredentials secures the flight connection as configured. Rotated
+// certificate files are picked up by new connections without a restart.
+func transportCredentials(cfg config.TLSConfig) (grpc.DialOption, error) {
+	if !cfg.Enabled {
+		return grpc.WithInsecure(), nil
+	}
+	reloader, err := tlsutil.NewReloader(tlsutil.Files{
+		CAFile: cfg.CAFile,
+		CertFile: cfg.CertFile,
+		KeyFile: cfg.KeyFile,
+		ServerName: cfg.ServerName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid flight tls config: %w", err)
+	}
+	return grpc.WithTransportCredentials(tlsutil.NewCredentials(reloader)), nil
+}
+
+// authOptions authenticates calls to the flight server as configured. Each
+// endpoint gets its own token source, so that handshake tokens issued by one
+// endpoint aren't sent to another. Calls to an endpoint are balanced over its
+// replicas, so a token must be valid on every replica, as it is when an
+// authenticating proxy or a shared token store sits in front of them.
+func authOptions(cfg config.AuthConfig) ([]grpc.DialOption, error) {
+	switch cfg.Mode {
+	case config.AuthBasic:
+		return flightauth.DialOptions(flightauth.NewBasicHandshake(cfg.Username, cfg.Password, cfg.TokenTTL)), nil
+	case config.AuthToken:
+		if cfg.TokenFile == "" {
+			return flightauth.DialOptions(flightauth.StaticToken(cfg.Token)), nil
+		}
+		source, err := flightauth.NewFileToken(cfg.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flight auth config: %w", err)
+		}
+		return flightauth.DialOptions(source), nil
+	default:
+		return nil, nil
+	}
+}
+
+// getScorer builds the scorer for every configured model, along with readiness
+// checks for their connections and circuit breakers. The fallback and
+// challengers aren't checked, since events are scored without them. With a
+// fallback the model checks are optional too, since events still get a
+// decision while the models are down.
+func getScorer(cfg *config.Config) (scoring.ModelScorer, []health.Check, error) {
+	conv := arrowconv.NewArrowConverter(memory.NewGoAllocator())
+	models := &modelFactory{cfg: cfg, conv: conv, conns: make(map[string]*grpc.ClientConn)}
+	var scorer scoring.ModelScorer
+	var err error
+	switch {
+	case len(cfg.Split.Variants) > 0:
+		scorer, err = getSplitScorer(cfg.Split, models)
+	case len(cfg.Routes) > 0:
+		scorer, err = getRouter(cfg.Routes, models)
+	default:
+		scorer, err = models.scorer("", nil)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fallback, err := getFallbackScorer(cfg, conv)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid fallback config: %w", err)
+	}
+	if fallback != nil {
+		scorer = scoring.NewFallbackScorer(scorer, fallback, cfg.Timeouts.PrimaryScore)
+	}
+	if len(cfg.Shadow.Challengers) > 0 {
+		scorer, err = getShadowScorer(cfg, scorer, conv)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	checks := models.checks()
+	if fallback != nil {
+		for i := range checks {
+			checks[i].Optional = true
+		}
+	}
+	return scorer, checks, nil
+}
+
+func getRouter(cfgs []config.RouteConfig, models *modelFactory) (scoring.ModelScorer, error) {
+	routes := make([]scoring.Route, 0, len(cfgs))
+	for _, rc := range cfgs {
+		path := rc.Path
+		if len(path) == 0 {
+			path = []string{rc.Model}
+		}
+		scorer, err := models.scorer(rc.Endpoint, path)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("routing events to model: event: %s, model: %s", rc.EventType, rc.Model)
+		routes = append(routes, scoring.Route{EventType: rc.EventType, Model: rc.Model, Scorer: scorer})
+	}
+	return scoring.NewRouter(routes)
+}
+
+func getSplitScorer(cfg config.SplitConfig, models *modelFactory) (scoring.ModelScorer, error) {
+	variants := make([]scoring.Variant, 0, len(cfg.Variants))
+	for _, vc := range cfg.Variants {
+		path := vc.Path
+		if len(path) == 0 {
+			path = []string{vc.Name}
+		}
+		scorer, err := models.scorer(vc.Endpoint, path)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("splitting traffic to variant: variant: %s, weight: %d", vc.Name, vc.Weight)
+		variants = append(variants, scoring.Variant{Name: vc.Name, Weight: vc.Weight, Scorer: scorer})
+	}
+	return scoring.NewSplitScorer(scoring.SplitKey{Attribute: cfg.Attribute, Field: cfg.Field}, variants)
+}
+
+// modelFactory builds a scorer per model, sharing one flight connection
+// between the models served at an endpoint.
+type modelFactory struct {
+	cfg *config.Config
+	conv *arrowconv.ArrowConverter
+	conns map[string]*grpc.ClientConn
+	endpoints []string
+	breakers []health.Check
+}
+
+// scorer stacks batching, retries and a circuit breaker on a flight scorer
+// for the model at path, so that one model failing doesn't trip the others.
+// An empty endpoint is the default model's replicas.
+func (f *modelFactory) scorer(endpoint string, path []string) (scoring.ModelScorer, error) {
+	cfg := f.cfg
+	addrs := []string{endpoint}
+	if endpoint == "" {
+		addrs = cfg.Flight.Endpoints
+		endpoint = strings.Join(addrs, ",")
+	}
+	conn, ok := f.conns[endpoint]
+	if !ok {
+		var err error
+		conn, err = dialFlight(addrs, cfg.Flight)
+		if err != nil {
+			return nil, fmt.Errorf("failed to instantiate flight client: endpoint: %s, reason: %w", endpoint, err)
+		}
+		f.conns[endpoint] = conn
+		f.endpoints = append(f.endpoints, endpoint)
+	}
+
+	flightScorer := newFlightScorer(conn, f.conv, path, cfg.Flight)
+	go discoverSchemas(flightScorer, cfg.Flight.DiscoveryInterval)
+	var scorer scoring.ModelScorer = flightScorer
+	if cfg.Batching.Size > 1 {
+		scorer = scoring.NewBatchingScorer(flightScorer, cfg.Batching.Size, cfg.Batching.Wait)
+	}
+	policy := scoring.RetryPolicy{
+		MaxAttempts: cfg.Retry.MaxAttempts,
+		InitialBackoff: cfg.Retry.InitialBackoff,
+		MaxBackoff: cfg.Retry.MaxBackoff,
+		Multiplier: cfg.Retry.Multiplier,
+	}
+	breaker := scoring.NewCircuitBreakerScorer(scoring.NewRetryingScorer(scorer, policy), breakerConfig(cfg))
+	name := "breaker:" + endpoint
+	if len(path) > 0 {
+		name += "/" + strings.Join(path, "/")
+	}
+	f.breakers = append(f.breakers, breakerCheck(name, breaker))
+	return breaker, nil
+}
+
+// checks reports the state of each connection the models use, and of each
+// model's circuit breaker.
+func (f *modelFactory) checks() []health.Check {
+	checks := make([]health.Check, 0, len(f.endpoints)+len(f.breakers))
+	for _, endpoint := range f.endpoints {
+		checks = append(checks, health.ConnCheck("flight:"+endpoint, f.conns[endpoint]))
+	}
+	return append(checks, f.breakers...)
+}
+
+// breakerCheck is down while the breaker is open. A half open breaker is up,
+// so that traffic keeps arriving to make the trial request that closes it.
+func breakerCheck(name string, breaker *scoring.CircuitBreakerScorer) health.Check {
+	return health.Check{Name: name, Probe: func(ctx context.Context) (string, error) {
+		state := breaker.State()
+		if state == scoring.BreakerOpen {
+			return state.String(), scoring.ErrCircuitOpen
+		}
+		return state.String(), nil
+	}}
+}
+
+// discoverSchemas fetches the schemas the model advertises, then keeps them
+// fresh. It runs alongside startup, so the first events may be scored before
+// the schemas are known, and a model server that is unreachable at startup
+// only delays validation until a refresh succeeds.
+func discoverSchemas(scorer *scoring.FlightModelScorer, interval time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+	err := scorer.Discover(ctx)
+	if err != nil {
+		log.Printf("error discovering model schemas, not validating until a refresh succeeds: %s", err)
+	} else if schemas := scorer.Schemas(); schemas.Input != nil || schemas.Output != nil {
+		log.Printf("validating against model schemas: input: %v, output: %v", schemas.Input, schemas.Output)
+	}
+	if interval > 0 {
+		scorer.WatchSchemas(interval, discoveryTimeout)
+	}
+}
+
+func breakerConfig(cfg *config.Config) scoring.BreakerConfig {
+	return scoring.BreakerConfig{
+		FailureThreshold: cfg.Breaker.FailureThreshold,
+		OpenTimeout: cfg.Breaker.OpenTimeout,
+	}
+}
+
+// getShadowScorer scores events against the configured challengers alongside
+// the champion.
+func getShadowScorer(cfg *config.Config, champion scoring.ModelScorer, conv *arrowconv.ArrowConverter) (scoring.ModelScorer, error) {
+	var out io.Writer = os.Stdout
+	if cfg.Shadow.Output != "" {
+		f, err := os.OpenFile(cfg.Shadow.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open shadow output: %w", err)
+		}
+		out = f
+	}
+	challengers := make([]scoring.Challenger, 0, len(cfg.Shadow.Challengers))
+	for _, cc := range cfg.Shadow.Challengers {
+		path := cc.Path
+		if len(path) == 0 {
+			path = []string{cc.Name}
+		}
+		conn, err := dialFlight([]string{cc.Endpoint}, cfg.Flight)
+		if err != nil {
+			return nil, fmt.Errorf("failed to instantiate challenger flight client: challenger: %s, reason: %w", cc.Name, err)
+		}
+		log.Printf("shadow scoring with challenger: challenger: %s, endpoint: %s", cc.Name, cc.Endpoint)
+		challengers = append(challengers, scoring.Challenger{
+			Name: cc.Name,
+			Scorer: scoring.NewCircuitBreakerScorer(newFlightScorer(conn, conv, path, cfg.Flight), breakerConfig(cfg)),
+		})
+	}
+	recorder := scoring.NewJSONLinesRecorder(out)
+	return scoring.NewShadowScorer(champion, challengers, recorder, cfg.Shadow.Timeout, cfg.Shadow.MaxInFlight), nil
+}
+
+// getFallbackScorer builds the configured fallback scorer, or returns nil when
+// there is none.
+func getFallbackScorer(cfg *config.Config, conv *arrowconv.ArrowConverter) (scoring.ModelScorer, error) {
+	switch cfg.Fallback.Mode {
+	case config.FallbackStatic:
+		return scoring.NewStaticScorer(cfg.Fallback.Scores), nil
+	case config.FallbackRules:
+		return scoring.LoadRulesScorer(cfg.Fallback.RulesFile)
+	case config.FallbackFlight:
+		conn, err := dialFlight([]string{cfg.Fallback.Endpoint}, cfg.Flight)
+		if err != nil {
+			return nil, err
+		}
+		return newFlightScorer(conn, conv, nil, cfg.Flight), nil
+	default:
+		return nil, nil
+	}
+}
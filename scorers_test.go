@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow/go/v7/arrow"
+	"github.com/apache/arrow/go/v7/arrow/array"
+	"github.com/apache/arrow/go/v7/arrow/flight"
+	"github.com/apache/arrow/go/v7/arrow/ipc"
+	"github.com/apache/arrow/go/v7/arrow/memory"
+	"github.com/ehenry2/avro-flight-decisioner/internal/arrowconv"
+	"github.com/ehenry2/avro-flight-decisioner/internal/config"
+	readiness "github.com/ehenry2/avro-flight-decisioner/internal/health"
+	"github.com/ehenry2/avro-flight-decisioner/internal/scoring"
+	"github.com/ehenry2/avro-flight-decisioner/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	replicaFeatureSchema = arrow.NewSchema([]arrow.Field{{Name: "amount", Type: arrow.PrimitiveTypes.Float64}}, nil)
+	replicaScoreSchema = arrow.NewSchema([]arrow.Field{{Name: "score", Type: arrow.PrimitiveTypes.Float64}}, nil)
+)
+
+// startReplica serves a model over DoExchange, scoring each row with twice
+// its amount and counting the rows it scores, along with the grpc health service.
+func startReplica(t *testing.T, rows *int32) (string, *health.Server) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	flight.RegisterFlightServiceService(server, &flight.FlightServiceService{
+		DoExchange: func(stream flight.FlightService_DoExchangeServer) error {
+			reader, err := flight.NewRecordReader(stream)
+			if err != nil {
+				return err
+			}
+			defer reader.Release()
+			writer := flight.NewRecordWriter(stream, ipc.WithSchema(replicaScoreSchema))
+			defer writer.Close()
+			for reader.Next() {
+				amounts := reader.Record().Column(0).(*array.Float64)
+				builder := array.NewRecordBuilder(memory.DefaultAllocator, replicaScoreSchema)
+				for i := 0; i < amounts.Len(); i++ {
+					builder.Field(0).(*array.Float64Builder).Append(amounts.Value(i) * 2)
+				}
+				record := builder.NewRecord()
+				builder.Release()
+				atomic.AddInt32(rows, int32(amounts.Len()))
+				err = writer.Write(record)
+				record.Release()
+				if err != nil {
+					return err
+				}
+			}
+			return reader.Err()
+		},
+	})
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return lis.Addr().String(), healthServer
+}
+
+// newReplicaScorer scores against the replicas at addrs as getScorer would.
+func newReplicaScorer(t *testing.T, addrs []string, balancing string) *scoring.FlightModelScorer {
+	cfg := config.Default().Flight
+	cfg.Balancing = balancing
+	cfg.StreamMaxRequests = 5
+	conn, err := dialFlight(addrs, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scorer := newFlightScorer(conn, arrowconv.NewArrowConverter(memory.NewGoAllocator()), nil, cfg)
+	t.Cleanup(func() {
+		scorer.Close()
+		conn.Close()
+	})
+	return scorer
+}
+
+// scoreReplicas scores n events one after another, stopping at the first
+// that isn't scored correctly.
+func scoreReplicas(scorer scoring.ModelScorer, n int) error {
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		scores, err := scorer.ScoreModel(ctx, replicaFeatureSchema, map[string]interface{}{"amount": 1.5})
+		cancel()
+		if err != nil {
+			return err
+		}
+		if scores["score"] != 3.0 {
+			return fmt.Errorf("unexpected scores: %v", scores)
+		}
+	}
+	return nil
+}
+
+func TestFlightScorer_balances_replicas(t *testing.T) {
+	for _, balancing := range []string{config.BalancingRoundRobin, config.BalancingLeastRequest} {
+		t.Run(balancing, func(t *testing.T) {
+			var rowsA, rowsB int32
+			a, _ := startReplica(t, &rowsA)
+			b, _ := startReplica(t, &rowsB)
+			scorer := newReplicaScorer(t, []string{a, b}, balancing)
+			assert.Eventually(t, func() bool {
+				err := scoreReplicas(scorer, 10)
+				return err == nil && atomic.LoadInt32(&rowsA) > 0 && atomic.LoadInt32(&rowsB) > 0
+			}, 5*time.Second, time.Millisecond, "scoring should reach both replicas")
+		})
+	}
+}
+
+func TestFlightScorer_ejects_unhealthy_replicas(t *testing.T) {
+	var rowsA, rowsB int32
+	a, _ := startReplica(t, &rowsA)
+	b, healthB := startReplica(t, &rowsB)
+	healthB.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	scorer := newReplicaScorer(t, []string{a, b}, config.BalancingRoundRobin)
+
+	assert.Nil(t, scoreReplicas(scorer, 20))
+	assert.Equal(t, int32(20), atomic.LoadInt32(&rowsA))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&rowsB), "a replica that isn't serving should score nothing")
+}
+
+func TestFlightScorer_ejects_replicas_that_stop_serving(t *testing.T) {
+	var rowsA, rowsB int32
+	a, _ := startReplica(t, &rowsA)
+	b, healthB := startReplica(t, &rowsB)
+	scorer := newReplicaScorer(t, []string{a, b}, config.BalancingRoundRobin)
+	assert.Eventually(t, func() bool {
+		err := scoreReplicas(scorer, 10)
+		return err == nil && atomic.LoadInt32(&rowsB) > 0
+	}, 5*time.Second, time.Millisecond)
+
+	// streams already open on the replica are retired by their limits.
+	healthB.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	assert.Eventually(t, func() bool {
+		before := atomic.LoadInt32(&rowsB)
+		err := scoreReplicas(scorer, 20)
+		return err == nil && atomic.LoadInt32(&rowsB) == before
+	}, 5*time.Second, time.Millisecond, "the replica should stop scoring once it isn't serving")
+}
+
+func TestBreakerCheck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	failing := mocks.NewMockModelScorer(ctrl)
+	failing.EXPECT().ScoreModel(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("model server down"))
+	breaker := scoring.NewCircuitBreakerScorer(failing, scoring.BreakerConfig{FailureThreshold: 1, OpenTimeout: 20 * time.Millisecond})
+	check := breakerCheck("breaker:model-a:9998", breaker)
+
+	state, err := check.Probe(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "closed", state)
+
+	_, _ = breaker.ScoreModel(context.Background(), nil, nil)
+	state, err = check.Probe(context.Background())
+	assert.ErrorIs(t, err, scoring.ErrCircuitOpen)
+	assert.Equal(t, "open", state)
+
+	time.Sleep(20 * time.Millisecond)
+	state, err = check.Probe(context.Background())
+	assert.Nil(t, err, "a half open breaker should take traffic for its trial request")
+	assert.Equal(t, "half-open", state)
+}
+
+func TestGetScorer_fallback_keeps_readiness(t *testing.T) {
+	cfg := config.Default()
+	cfg.Flight.Endpoints = []string{"127.0.0.1:1"}
+
+	// without a fallback an unreachable model makes the service unready.
+	_, checks, err := getScorer(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := readiness.Run(context.Background(), checks)
+	assert.Equal(t, readiness.StatusDown, report.Status)
+
+	// with one, events still get a decision so the model is only reported.
+	cfg.Fallback = config.FallbackConfig{Mode: config.FallbackStatic, Scores: map[string]interface{}{"score": 0.5}}
+	scorer, checks, err := getScorer(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report = readiness.Run(context.Background(), checks)
+	assert.Equal(t, readiness.StatusUp, report.Status, "a fallback should keep the service ready: %+v", report)
+	flight := report.Checks["flight:127.0.0.1:1"]
+	assert.Equal(t, readiness.StatusDown, flight.Status, "the model connection should still be reported")
+	assert.True(t, flight.Optional)
+	assert.Contains(t, report.Checks, "breaker:127.0.0.1:1", "the model's breaker should still be reported")
+
+	ctx, decision := scoring.WithDecision(context.Background())
+	scores, err := scorer.ScoreModel(ctx, replicaFeatureSchema, map[string]interface{}{"amount": 1.0})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"score": 0.5}, scores)
+	assert.True(t, decision.Fallback, "events should be scored by the fallback")
+}